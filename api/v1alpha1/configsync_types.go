@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -24,10 +26,190 @@ import (
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 // SourceSpec describes the source of configuration data for a ConfigSync.
-// Only one of the fields should be set.
+// Exactly one of the fields must be set.
+// +kubebuilder:validation:XValidation:rule="[has(self.git), has(self.oci), has(self.http), has(self.bucket), has(self.configMapRef), has(self.secretRef)].filter(x, x).size() == 1",message="exactly one source must be set"
 type SourceSpec struct {
 	// Git references a Git repository and path to read the configuration from.
 	Git *GitSource `json:"git,omitempty"`
+
+	// OCI references a configuration bundle published as an OCI artifact.
+	OCI *OCISource `json:"oci,omitempty"`
+
+	// HTTP downloads a tarball or zip archive over HTTP(S).
+	HTTP *HTTPSource `json:"http,omitempty"`
+
+	// Bucket downloads the objects under a prefix of an S3-compatible bucket.
+	Bucket *BucketSource `json:"bucket,omitempty"`
+
+	// ConfigMapRef reads the configuration from a ConfigMap in the
+	// ConfigSync's namespace. Changes to the ConfigMap are synced immediately.
+	ConfigMapRef *ObjectSource `json:"configMapRef,omitempty"`
+
+	// SecretRef reads the configuration from a Secret in the ConfigSync's
+	// namespace. Changes to the Secret are synced immediately.
+	SecretRef *ObjectSource `json:"secretRef,omitempty"`
+}
+
+// ObjectSource reads configuration from the keys of an in-cluster ConfigMap
+// or Secret. Each key is treated as a file of that name, so the object may
+// hold manifests or raw configuration files to be copied into ConfigMap and
+// Secret targets.
+type ObjectSource struct {
+	// Name is the name of the ConfigMap or Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Include is an optional list of glob patterns matched against the keys.
+	// When set, only matching keys are read.
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// Exclude is an optional list of glob patterns matched against the keys.
+	// Matching keys are skipped even if they match `include`.
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// OCISource references a configuration bundle published to an OCI registry.
+// Layers packaged as (gzipped) tarballs are extracted; any other layer is
+// written to the file named by its `org.opencontainers.image.title`
+// annotation, the way `oras push` stores files.
+type OCISource struct {
+	// URL is the repository holding the artifact, for example
+	// `oci://ghcr.io/myorg/configs`.
+	// +kubebuilder:validation:Pattern=`^oci://`
+	URL string `json:"url"`
+
+	// Ref selects the artifact. Defaults to the `latest` tag.
+	// +optional
+	Ref *OCIRef `json:"ref,omitempty"`
+
+	// Path is the path within the artifact to either a single file or a
+	// directory that is walked recursively. Defaults to the artifact root.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Include is an optional list of glob patterns matched against file paths
+	// relative to `path`. When set, only matching files are read.
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// Exclude is an optional list of glob patterns matched against file paths
+	// relative to `path`. Matching files are skipped even if they match `include`.
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+
+	// SecretRef references a Secret of type `kubernetes.io/dockerconfigjson`
+	// in the ConfigSync's namespace holding the registry credentials.
+	// +optional
+	SecretRef *LocalObjectReference `json:"secretRef,omitempty"`
+
+	// Insecure connects to the registry over plain HTTP.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// OCIRef selects an artifact. When several fields are set, `digest` wins
+// over `tag`, which wins over `semver`.
+type OCIRef struct {
+	// Digest pins the artifact manifest, for example `sha256:...`.
+	// +kubebuilder:validation:Pattern=`^sha256:[0-9a-f]{64}$`
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// Tag is the tag to pull.
+	// +optional
+	Tag string `json:"tag,omitempty"`
+
+	// Semver pulls the highest tag that is a semantic version within this
+	// range, for example `>=1.2.0 <2.0.0` or `~1.4`.
+	// +optional
+	Semver string `json:"semver,omitempty"`
+}
+
+// HTTPSource downloads an archive over HTTP(S). Gzipped tarballs, plain
+// tarballs and zip archives are extracted; the archive format is detected
+// from its content.
+type HTTPSource struct {
+	// URL of the archive, for example
+	// `https://example.com/releases/configs-1.2.0.tar.gz`.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+
+	// Checksum is the expected digest of the archive, for example
+	// `sha256:...`. Archives that do not match are not applied.
+	// +kubebuilder:validation:Pattern=`^sha256:[0-9a-f]{64}$`
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
+	// Path is the path within the archive to either a single file or a
+	// directory that is walked recursively. Defaults to the archive root.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Include is an optional list of glob patterns matched against file paths
+	// relative to `path`. When set, only matching files are read.
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// Exclude is an optional list of glob patterns matched against file paths
+	// relative to `path`. Matching files are skipped even if they match `include`.
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+
+	// HeadersSecretRef references a Secret in the ConfigSync's namespace whose
+	// keys are sent as request headers, for example an `Authorization` key
+	// holding `Bearer <token>`.
+	// +optional
+	HeadersSecretRef *LocalObjectReference `json:"headersSecretRef,omitempty"`
+}
+
+// BucketSource downloads the objects under a prefix of an S3-compatible
+// bucket, such as AWS S3, MinIO or Google Cloud Storage in interoperability
+// mode. Requests use path-style addressing.
+type BucketSource struct {
+	// Endpoint is the host (and optional port) of the storage service, for
+	// example `s3.eu-west-1.amazonaws.com` or `minio.storage:9000`.
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+
+	// Bucket is the name of the bucket.
+	// +kubebuilder:validation:MinLength=1
+	Bucket string `json:"bucket"`
+
+	// Prefix limits the download to objects whose key starts with it. The
+	// prefix is stripped from the object keys to form file paths.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Region used to sign requests. Defaults to `us-east-1`.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Path is the path, relative to `prefix`, to either a single object or a
+	// directory that is walked recursively. Defaults to `prefix` itself.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Include is an optional list of glob patterns matched against file paths
+	// relative to `path`. When set, only matching files are read.
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// Exclude is an optional list of glob patterns matched against file paths
+	// relative to `path`. Matching files are skipped even if they match `include`.
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+
+	// SecretRef references a Secret in the ConfigSync's namespace holding the
+	// `accessKeyID` and `secretAccessKey` (and optionally `sessionToken`)
+	// keys. Requests are anonymous when unset.
+	// +optional
+	SecretRef *LocalObjectReference `json:"secretRef,omitempty"`
+
+	// Insecure connects to the endpoint over plain HTTP.
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
 }
 
 type GitSource struct {
@@ -36,21 +218,41 @@ type GitSource struct {
 	// +kubebuilder:validation:Required
 	RepoURL string `json:"repoURL"`
 
-	// Path is the repository-relative path to the file containing the configuration
-	// (for example `config/app.yaml`). This field is required when `git` is used.
+	// Path is the repository-relative path to either a single file containing the
+	// configuration (for example `config/app.yaml`) or a directory that is walked
+	// recursively (for example `deploy/my-app`). Paths that escape the repository,
+	// either through `..` or through symlinks, are rejected. This field is required
+	// when `git` is used.
 	// +kubebuilder:validation:Required
 	Path string `json:"path"`
 
+	// Include is an optional list of glob patterns (for example `**/*.yaml` or
+	// `overlays/prod/*`) matched against file paths relative to `path`. When set,
+	// only matching files are read. Ignored when `path` points at a single file.
+	// +optional
+	Include []string `json:"include,omitempty"`
+
+	// Exclude is an optional list of glob patterns matched against file paths
+	// relative to `path`. Matching files are skipped even if they match `include`.
+	// Ignored when `path` points at a single file.
+	// +optional
+	Exclude []string `json:"exclude,omitempty"`
+
 	// Branch is the Git branch to checkout. If unspecified, the operator will
 	// default to the repository's default branch.
 	// +optional
 	Branch string `json:"branch,omitempty"`
 
-	// Revision is an optional Git revision (branch, tag, or commit SHA). If
-	// unspecified, the operator will default to the repository's default branch.
+	// Revision is an optional full commit SHA to check out. Prefer
+	// `ref.commit`, which takes precedence when both are set.
 	// +optional
 	Revision string `json:"revision,omitempty"`
 
+	// Ref pins the checkout to a commit, a tag or the highest tag within a
+	// semver range. When set, it takes precedence over `branch`.
+	// +optional
+	Ref *GitRef `json:"ref,omitempty"`
+
 	// AuthMethod controls how the operator authenticates to the Git repository.
 	// Allowed values are `ssh`, `https`, or `none`.
 	// +kubebuilder:validation:Enum=ssh;https;none
@@ -58,9 +260,176 @@ type GitSource struct {
 
 	// AuthSecretRef references a Secret that contains an SSH private key or basic auth credentials when
 	// `AuthMethod=ssh` or `AuthMethod=https`. The Secret should contain the key under a standard key
-	// name (e.g., `id_rsa` for SSH or `username` and `password` for HTTPS).
+	// name (e.g., `id_rsa` for SSH or `username` and `password` for HTTPS). For SSH it may also carry
+	// the trusted host keys under `known_hosts`. The namespace defaults to the ConfigSync's namespace.
 	// +optional
 	AuthSecretRef *ObjectRef `json:"authSecretRef,omitempty"`
+
+	// InsecureSkipHostKeyVerification disables SSH host key verification when
+	// `AuthMethod=ssh`. By default host keys are checked against the
+	// `known_hosts` key of the auth Secret, falling back to the operator's
+	// default known_hosts ConfigMap, and the fetch fails when neither is set.
+	// +optional
+	InsecureSkipHostKeyVerification bool `json:"insecureSkipHostKeyVerification,omitempty"`
+
+	// TLS configures the certificates used to reach HTTPS repositories, for
+	// example a Git server behind a private CA or requiring client certificates.
+	// +optional
+	TLS *GitTLS `json:"tls,omitempty"`
+
+	// Proxy routes connections to the repository through a proxy.
+	// +optional
+	Proxy *GitProxy `json:"proxy,omitempty"`
+
+	// Verify requires the fetched revision to be signed by a trusted key.
+	// Unsigned revisions and revisions signed by any other key are not applied.
+	// +optional
+	Verify *GitVerification `json:"verify,omitempty"`
+
+	// Depth limits the clone to the given number of commits from the tip of
+	// each fetched ref. Pinned commits must lie within that depth. Zero, the
+	// default, fetches the full history.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Depth int `json:"depth,omitempty"`
+
+	// SingleBranch fetches only `branch`, or the default branch when unset,
+	// even when a revision or `ref.commit` is pinned; the pinned commit must
+	// then be reachable from that branch. Tags are skipped unless `ref.tag`,
+	// `ref.semver` or tag verification needs them.
+	// +optional
+	SingleBranch bool `json:"singleBranch,omitempty"`
+
+	// SparseCheckout checks out only `path` instead of the whole repository.
+	// Files outside of `path`, such as Helm values files or kustomize bases
+	// elsewhere in the repository, are then not available for rendering.
+	// +optional
+	SparseCheckout bool `json:"sparseCheckout,omitempty"`
+
+	// MaxSize fails the fetch when the history of the synced commit (its Git
+	// objects, uncompressed, down to the `depth` boundary) and its checkout
+	// grow beyond this size (for example `2Gi`). Objects fetched for other refs
+	// of the same repository don't count, and the shared cache is left for
+	// eviction to clean up.
+	// +optional
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+}
+
+// GitVerification configures signature verification of Git revisions.
+type GitVerification struct {
+	// Mode selects what must carry the signature. `HEAD` verifies the
+	// checked-out commit; `Tag` requires an annotated tag pointing at the
+	// checked-out commit. Defaults to `HEAD`.
+	// +kubebuilder:validation:Enum=HEAD;Tag
+	// +kubebuilder:default=HEAD
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// SecretRef references a Secret in the ConfigSync's namespace holding the
+	// trusted keys. Each value is either ASCII-armored OpenPGP public keys or
+	// SSH public keys in `authorized_keys` format.
+	SecretRef LocalObjectReference `json:"secretRef"`
+}
+
+const (
+	// VerificationModeHEAD verifies the signature of the checked-out commit.
+	VerificationModeHEAD = "HEAD"
+	// VerificationModeTag verifies the signature of an annotated tag pointing
+	// at the checked-out commit.
+	VerificationModeTag = "Tag"
+)
+
+// LocalObjectReference references an object in the ConfigSync's namespace.
+type LocalObjectReference struct {
+	// Name is the name of the referenced object.
+	Name string `json:"name"`
+}
+
+// GitRef selects the revision to check out. When several fields are set,
+// `commit` wins over `tag`, which wins over `semver`.
+type GitRef struct {
+	// Commit is a full commit SHA.
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{40}$`
+	// +optional
+	Commit string `json:"commit,omitempty"`
+
+	// Tag is the name of a tag, for example `v1.4.2`.
+	// +optional
+	Tag string `json:"tag,omitempty"`
+
+	// Semver is a semantic version range, for example `>=1.4.0 <2.0.0`. The
+	// tag with the highest version in range is checked out, so the ConfigSync
+	// floats to new releases as they are tagged. Tags that are not semantic
+	// versions are ignored; a leading `v` is allowed.
+	// +optional
+	Semver string `json:"semver,omitempty"`
+}
+
+// GitTLS configures TLS for HTTPS Git repositories.
+type GitTLS struct {
+	// CABundleRef references a ConfigMap or Secret holding PEM-encoded CA
+	// certificates that are trusted in addition to the system roots. The key
+	// defaults to `ca.crt`.
+	// +optional
+	CABundleRef *KeyReference `json:"caBundleRef,omitempty"`
+
+	// ClientCertSecretRef references a Secret holding a PEM-encoded client
+	// certificate and key under `tls.crt` and `tls.key`, as in a
+	// `kubernetes.io/tls` Secret. Namespace defaults to the ConfigSync's.
+	// +optional
+	ClientCertSecretRef *ObjectRef `json:"clientCertSecretRef,omitempty"`
+}
+
+// GitProxy configures the proxy used to reach a Git repository.
+type GitProxy struct {
+	// URL is the proxy to connect through, for example
+	// `http://proxy.internal:3128`. Credentials may be embedded as userinfo.
+	// +kubebuilder:validation:Pattern=`^(https?|socks5)://`
+	URL string `json:"url"`
+
+	// NoProxy lists hosts that are reached directly. Entries match a host name
+	// exactly or, when starting with `.`, any subdomain. IP ranges in CIDR
+	// notation and `*` (no proxy at all) are also accepted.
+	// +optional
+	NoProxy []string `json:"noProxy,omitempty"`
+}
+
+// KubeConfigSpec locates the kubeconfig of a remote cluster.
+type KubeConfigSpec struct {
+	// SecretRef references a Secret in the ConfigSync's namespace holding the
+	// kubeconfig. The key defaults to `value`. The kubeconfig must be
+	// self-contained: exec and auth-provider plugins are not supported.
+	SecretRef SecretKeyReference `json:"secretRef"`
+}
+
+// SecretKeyReference selects a data key of a Secret in the ConfigSync's
+// namespace.
+type SecretKeyReference struct {
+	// Name is the name of the Secret.
+	Name string `json:"name"`
+
+	// Key is the data key to read. Each referencing field documents its default.
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// KeyReference selects a data key of a ConfigMap or Secret.
+type KeyReference struct {
+	// Kind is the kind of the referenced object.
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+
+	// Name is the name of the referenced object.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the referenced object. Defaults to the
+	// ConfigSync's namespace.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Key is the data key to read. Each referencing field documents its default.
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 type TargetRef struct {
@@ -71,10 +440,43 @@ type TargetRef struct {
 	// Name is the name of the target ConfigMap or Secret.
 	Name string `json:"name"`
 
-	// Type is the type of the Kubernetes resource to write. Valid values are
-	// `ConfigMap` or `Secret`.
+	// Type is the type of the Kubernetes resource to write. `ConfigMap` and
+	// `Secret` generate a single object named `name` whose data keys are the
+	// source file names. `Deployment` applies the source manifests as-is.
 	// +kubebuilder:validation:Enum=ConfigMap;Secret;Deployment
 	Type string `json:"type"`
+
+	// Keys optionally renames source files when they are written into a
+	// generated ConfigMap or Secret. Files without a mapping keep their file
+	// name as key. Ignored for other target types.
+	// +optional
+	Keys []KeyMapping `json:"keys,omitempty"`
+}
+
+// KeyMapping maps a source file onto a data key of a generated ConfigMap or Secret.
+type KeyMapping struct {
+	// File is the name of the source file.
+	File string `json:"file"`
+
+	// Key is the data key the file's content is stored under.
+	Key string `json:"key"`
+}
+
+// ResourceRef identifies a single object applied by a ConfigSync.
+type ResourceRef struct {
+	// Group is the API group of the object; empty for the core group.
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Kind is the kind of the object.
+	Kind string `json:"kind"`
+
+	// Namespace is the namespace of the object; empty for cluster-scoped objects.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name is the name of the object.
+	Name string `json:"name"`
 }
 
 type ObjectRef struct {
@@ -93,8 +495,9 @@ type ConfigSyncSpec struct {
 	// More info: https://book.kubebuilder.io/reference/markers/crd-validation.html
 
 	// foo is an example field of ConfigSync. Edit configsync_types.go to remove/update
-	// Source defines where to fetch configuration data from. Only the `git` field
-	// is supported as the source in this operator.
+	// Source defines where to fetch configuration data from: a Git repository,
+	// an OCI artifact, an HTTP(S) archive, an S3-compatible bucket or an
+	// in-cluster ConfigMap or Secret.
 	// +optional
 	Source SourceSpec `json:"source"`
 
@@ -108,6 +511,370 @@ type ConfigSyncSpec struct {
 	// (e.g. `10m`, `1h`). If omitted, the operator's default behavior applies.
 	// +kubebuilder:validation:Pattern=^([0-9]+(\\.[0-9]+)?(ns|us|ms|s|m|h))+$
 	RefreshInterval string `json:"refreshInterval,omitempty"`
+
+	// Prune enables deletion of objects that were applied at the previous
+	// revision but are no longer part of the rendered configuration. Objects
+	// annotated with `configs.example.io/prune: disabled` are never pruned.
+	// +optional
+	Prune bool `json:"prune,omitempty"`
+
+	// DeletionPolicy controls what happens to the applied objects when the
+	// ConfigSync is deleted. `Delete` removes every object in the inventory
+	// (except those annotated with `configs.example.io/prune: disabled`), while
+	// `Orphan` leaves them in place and only releases the operator's field
+	// ownership. Defaults to `Orphan`. When the objects can't be reached to
+	// release them, `Orphan` gives up and leaves them untouched; `Delete` keeps
+	// retrying until the `configs.example.io/skip-cleanup` annotation is set.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Orphan
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// DriftPolicy controls what happens when applied objects are found to differ
+	// from the rendered configuration while the source revision is unchanged
+	// (for example after a `kubectl edit`). `Correct` re-applies the drifted
+	// objects, `Report` only records them in status. Defaults to `Correct`.
+	// +kubebuilder:validation:Enum=Correct;Report
+	// +kubebuilder:default=Correct
+	// +optional
+	DriftPolicy string `json:"driftPolicy,omitempty"`
+
+	// ServiceAccountName names a ServiceAccount in the ConfigSync's namespace
+	// whose permissions are used to apply, prune and read the synced objects.
+	// The operator impersonates it, so objects the ServiceAccount may not
+	// manage are rejected with the `PermissionDenied` reason. When unset, the
+	// operator's own permissions are used.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// KubeConfig deploys to a remote cluster instead of the one the operator
+	// runs in. Applies, prunes and reads all go to the remote cluster; combined
+	// with `serviceAccountName`, the ServiceAccount of that name in the remote
+	// cluster is impersonated.
+	// +optional
+	KubeConfig *KubeConfigSpec `json:"kubeConfig,omitempty"`
+
+	// Render configures how source files are rendered before they are applied.
+	// +optional
+	Render *RenderSpec `json:"render,omitempty"`
+
+	// Wait makes the operator follow the health of the applied objects after
+	// every apply: they are re-checked every few seconds until all of them are
+	// healthy, and the ConfigSync is marked not `Ready` with the
+	// `HealthCheckTimeout` reason if that takes longer than `wait.timeout`.
+	// Without it, health is only re-checked at the refresh interval.
+	// +optional
+	Wait *WaitSpec `json:"wait,omitempty"`
+
+	// DependsOn lists ConfigSyncs that must be `Ready` at their current
+	// generation before this one is synced. Until then it is not `Ready`, with
+	// the `DependencyNotReady` reason, and it is reconciled again as soon as a
+	// dependency turns ready. Circular dependencies are reported with the
+	// `DependencyCycle` reason.
+	// +optional
+	DependsOn []DependencyReference `json:"dependsOn,omitempty"`
+
+	// Suspend stops fetching the source and applying objects, for example
+	// during an incident, while leaving the applied objects in place. The
+	// ConfigSync reports a `Suspended` condition meanwhile. A single sync can
+	// still be requested by setting the `configs.example.io/reconcile-at`
+	// annotation to a new value.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Rollback re-applies a prior revision, such as one listed in
+	// `status.history`, instead of the source's latest revision. Forward sync
+	// is paused until it is removed. Only Git commits and OCI artifact digests
+	// can be rolled back to.
+	// +optional
+	Rollback *RollbackSpec `json:"rollback,omitempty"`
+}
+
+// RollbackSpec selects the revision to roll back to.
+type RollbackSpec struct {
+	// Revision is a Git commit SHA or an OCI artifact digest.
+	// +kubebuilder:validation:MinLength=1
+	Revision string `json:"revision"`
+}
+
+// DependencyReference identifies a ConfigSync another one depends on.
+type DependencyReference struct {
+	// Name is the name of the ConfigSync.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the ConfigSync. Defaults to the
+	// namespace of the depending ConfigSync.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// WaitSpec configures how long applied objects may take to become healthy.
+type WaitSpec struct {
+	// Timeout is how long after an apply the objects may take to become healthy.
+	// Defaults to `5m`.
+	// +kubebuilder:default="5m"
+	// +optional
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+
+	// RollbackOnFailure re-applies the newest revision in `status.history`
+	// that became healthy when a new revision fails its health checks or
+	// times out. Forward sync stays paused until the source moves past the
+	// failed revision.
+	// +optional
+	RollbackOnFailure bool `json:"rollbackOnFailure,omitempty"`
+}
+
+// RenderSpec describes the rendering stages applied to source files.
+type RenderSpec struct {
+	// Template renders every source file through Go text/template (with Sprig
+	// helpers) before it is parsed.
+	// +optional
+	Template *TemplateRender `json:"template,omitempty"`
+
+	// Kustomize runs a kustomize build on the source path (which must contain a
+	// kustomization file) and applies the resulting objects. Kustomize reads the
+	// files directly, so `template` only applies to ConfigMap and Secret targets
+	// when both are set.
+	// +optional
+	Kustomize *KustomizeRender `json:"kustomize,omitempty"`
+
+	// Helm renders a Helm chart, read from the source path or pulled from a
+	// chart repository, and applies the resulting objects. No Helm release is
+	// created. Like `kustomize`, it only replaces the manifests of targets that
+	// are not ConfigMaps or Secrets, and it cannot be combined with `kustomize`.
+	// +optional
+	Helm *HelmRender `json:"helm,omitempty"`
+}
+
+// HelmRender configures how a Helm chart is rendered, equivalent to running
+// `helm template`. Hooks and chart tests are not applied, and templates cannot
+// look up objects in the cluster.
+type HelmRender struct {
+	// Chart pulls the chart from an HTTP(S) chart repository or OCI registry.
+	// When unset, the chart is read from the source path.
+	// +optional
+	Chart *HelmChartSource `json:"chart,omitempty"`
+
+	// ReleaseName is exposed to templates as `.Release.Name`. Defaults to the
+	// name of the ConfigSync.
+	// +optional
+	ReleaseName string `json:"releaseName,omitempty"`
+
+	// Namespace is exposed to templates as `.Release.Namespace`. Defaults to
+	// the namespace of the ConfigSync.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Values are inline chart values. They take precedence over values loaded
+	// through `valuesFiles` and `valuesFrom`.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Values *apiextensionsv1.JSON `json:"values,omitempty"`
+
+	// ValuesFiles are repository-relative paths to values files in the source.
+	// Later files take precedence over earlier ones.
+	// +optional
+	ValuesFiles []string `json:"valuesFiles,omitempty"`
+
+	// ValuesFrom references ConfigMaps or Secrets in the ConfigSync's namespace
+	// to load chart values from. They take precedence over `valuesFiles`, and
+	// later entries take precedence over earlier ones.
+	// +optional
+	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+
+	// IncludeCRDs renders the chart's `crds/` directory along with its templates.
+	// +optional
+	IncludeCRDs bool `json:"includeCRDs,omitempty"`
+}
+
+// HelmChartSource identifies a chart in a chart repository.
+type HelmChartSource struct {
+	// RepoURL is either the URL of an HTTP(S) chart repository serving
+	// `index.yaml` (for example `https://charts.example.com`) or an OCI
+	// registry path (for example `oci://registry.example.com/charts`).
+	// +kubebuilder:validation:Pattern=`^(https?|oci)://`
+	RepoURL string `json:"repoURL"`
+
+	// Name is the name of the chart.
+	Name string `json:"name"`
+
+	// Version is an exact chart version or a semver constraint (for example
+	// `~1.4.0`). The newest matching version is used; the newest version
+	// overall when empty.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// AuthSecretRef references a Secret with `username` and `password` keys used
+	// to authenticate to the repository. The Secret must be in the ConfigSync's
+	// namespace.
+	// +optional
+	AuthSecretRef *ObjectRef `json:"authSecretRef,omitempty"`
+}
+
+// KustomizeRender configures an in-process kustomize build of the source path.
+// The overrides behave like the equivalent fields of a kustomization file
+// layered on top of the source. The build can only read files from the
+// fetched source: bases outside of it and remote bases or files (Git or HTTP
+// URLs) are rejected rather than fetched.
+type KustomizeRender struct {
+	// Namespace overrides the namespace of every namespaced object.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// NamePrefix is prepended to the name of every object.
+	// +optional
+	NamePrefix string `json:"namePrefix,omitempty"`
+
+	// CommonLabels are added to every object and its selectors.
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+
+	// Images overrides container image names, tags or digests.
+	// +optional
+	Images []KustomizeImage `json:"images,omitempty"`
+}
+
+// KustomizeImage overrides a container image, matching the kustomize `images` field.
+type KustomizeImage struct {
+	// Name is the image name to match (without tag or digest).
+	Name string `json:"name"`
+
+	// NewName replaces the image name.
+	// +optional
+	NewName string `json:"newName,omitempty"`
+
+	// NewTag replaces the image tag.
+	// +optional
+	NewTag string `json:"newTag,omitempty"`
+
+	// Digest replaces the image tag with a digest.
+	// +optional
+	Digest string `json:"digest,omitempty"`
+}
+
+// TemplateRender configures Go text/template rendering of source files.
+// Templates can reference `.Values`, `.ConfigSync.Name`, `.ConfigSync.Namespace`,
+// `.Target.Name`, `.Target.Namespace`, `.Target.Type` and `.Revision`.
+type TemplateRender struct {
+	// Values are inline template values. They take precedence over values
+	// loaded through `valuesFrom`.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Values *apiextensionsv1.JSON `json:"values,omitempty"`
+
+	// ValuesFrom references ConfigMaps or Secrets in the ConfigSync's namespace
+	// to load template values from. Later entries take precedence over earlier ones.
+	// +optional
+	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+}
+
+// ValuesReference points at template values stored in a ConfigMap or Secret.
+type ValuesReference struct {
+	// Kind is the kind of the referenced object.
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+
+	// Name is the name of the referenced object in the ConfigSync's namespace.
+	Name string `json:"name"`
+
+	// Key selects a single data key whose content is parsed as YAML and merged
+	// into the values. If unset, every data key becomes a top-level string value.
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// Optional skips the reference instead of failing when the object or key
+	// does not exist.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// ReconcileRequestAnnotation requests a sync of a ConfigSync, even a suspended
+// one, whenever it is set to a new value such as the current time.
+const ReconcileRequestAnnotation = "configs.example.io/reconcile-at"
+
+// SkipCleanupAnnotation, set to "true" on a ConfigSync, lets it be deleted
+// without deleting or orphaning its applied objects, for example when the
+// cluster they live in is gone for good.
+const SkipCleanupAnnotation = "configs.example.io/skip-cleanup"
+
+const (
+	// DeletionPolicyDelete deletes applied objects together with the ConfigSync.
+	DeletionPolicyDelete = "Delete"
+	// DeletionPolicyOrphan leaves applied objects behind when the ConfigSync is deleted.
+	DeletionPolicyOrphan = "Orphan"
+
+	// DriftPolicyCorrect re-applies objects that drifted from the source.
+	DriftPolicyCorrect = "Correct"
+	// DriftPolicyReport only reports objects that drifted from the source.
+	DriftPolicyReport = "Report"
+
+	// HealthCurrent means an object has reached its desired state.
+	HealthCurrent = "Current"
+	// HealthInProgress means an object is still being rolled out.
+	HealthInProgress = "InProgress"
+	// HealthFailed means an object cannot reach its desired state without
+	// intervention, such as a Deployment past its progress deadline.
+	HealthFailed = "Failed"
+	// HealthNotFound means an object in the inventory no longer exists.
+	HealthNotFound = "NotFound"
+
+	// SyncResultApplied means a revision was applied and its health is not known yet.
+	SyncResultApplied = "Applied"
+	// SyncResultHealthy means the objects of a revision became healthy.
+	SyncResultHealthy = "Healthy"
+	// SyncResultFailed means a revision failed to apply or its health checks.
+	SyncResultFailed = "Failed"
+)
+
+// SyncRecord describes a revision that was applied.
+type SyncRecord struct {
+	// Revision is the applied source revision.
+	Revision string `json:"revision"`
+
+	// Message is the first line of the commit message, for Git sources.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Author is the commit author, for Git sources.
+	// +optional
+	Author string `json:"author,omitempty"`
+
+	// AppliedTime is when the revision was last applied.
+	AppliedTime metav1.Time `json:"appliedTime"`
+
+	// Result is one of `Applied`, `Healthy` or `Failed`.
+	// +kubebuilder:validation:Enum=Applied;Healthy;Failed
+	Result string `json:"result"`
+
+	// Objects is the number of objects applied.
+	// +optional
+	Objects int `json:"objects,omitempty"`
+
+	// Rollback is set when the revision was re-applied by a rollback.
+	// +optional
+	Rollback bool `json:"rollback,omitempty"`
+}
+
+// AutomaticRollback describes a rollback started by `wait.rollbackOnFailure`.
+type AutomaticRollback struct {
+	// FailedRevision is the revision that failed its health checks.
+	FailedRevision string `json:"failedRevision"`
+
+	// Revision is the healthy revision that was re-applied instead.
+	Revision string `json:"revision"`
+}
+
+// ObjectHealth is the health of an applied object.
+type ObjectHealth struct {
+	ResourceRef `json:",inline"`
+
+	// Status is one of `Current`, `InProgress`, `Failed` or `NotFound`.
+	// +kubebuilder:validation:Enum=Current;InProgress;Failed;NotFound
+	Status string `json:"status"`
+
+	// Message describes the object's progress, such as the number of ready replicas.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // ConfigSyncStatus defines the observed state of ConfigSync.
@@ -132,8 +899,10 @@ type ConfigSyncStatus struct {
 	// +optional
 	LastSyncedTime *metav1.Time `json:"lastSyncedTime,omitempty"`
 
-	// SourceRevision records the source revision (for example a Git SHA) that
-	// was applied during the last sync.
+	// SourceRevision records the source revision (a Git commit SHA, the
+	// digest of an OCI artifact, or the content digest of a downloaded
+	// archive, bucket prefix, ConfigMap or Secret) that was applied during
+	// the last sync.
 	// +optional
 	SourceRevision string `json:"sourceRevision,omitempty"`
 
@@ -146,6 +915,67 @@ type ConfigSyncStatus struct {
 	// +optional
 	SourcePath string `json:"sourcePath,omitempty"`
 
+	// SourceTag is the tag that was checked out or pulled when the source's
+	// `ref` selects a tag or a semver range.
+	// +optional
+	SourceTag string `json:"sourceTag,omitempty"`
+
+	// SourceSigner is the fingerprint of the key that signed the applied
+	// revision (or its tag) when `spec.source.git.verify` is set, for example
+	// an OpenPGP fingerprint or an SSH `SHA256:` fingerprint.
+	// +optional
+	SourceSigner string `json:"sourceSigner,omitempty"`
+
+	// ObservedGeneration is the ConfigSync generation that was applied during
+	// the last sync. A spec change (for example to `render`) triggers a new apply
+	// even when the source revision is unchanged.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// RenderDigest is a digest of the render inputs loaded from outside the
+	// ConfigSync (such as template values from `valuesFrom`) during the last
+	// sync. A change triggers a new apply even when the source revision is
+	// unchanged.
+	// +optional
+	RenderDigest string `json:"renderDigest,omitempty"`
+
+	// Inventory lists every object applied at the last synced revision. It is
+	// used to find objects to prune when they disappear from the source.
+	// +optional
+	Inventory []ResourceRef `json:"inventory,omitempty"`
+
+	// Drifted lists the objects whose live state differed from the rendered
+	// configuration during the last drift check and were left in place because
+	// `driftPolicy` is `Report`. Corrected objects are not listed.
+	// +optional
+	Drifted []ResourceRef `json:"drifted,omitempty"`
+
+	// LastAppliedTime is when the objects were last applied for a new revision
+	// or spec. `wait.timeout` counts from it.
+	// +optional
+	LastAppliedTime *metav1.Time `json:"lastAppliedTime,omitempty"`
+
+	// Health lists the health of every object in the inventory, as of the last
+	// sync. The `Ready` condition is `True` once all of them are `Current`.
+	// +optional
+	Health []ObjectHealth `json:"health,omitempty"`
+
+	// History lists the most recently applied revisions, newest first, with
+	// the outcome of their apply and health checks. It is bounded to 10
+	// entries.
+	// +optional
+	History []SyncRecord `json:"history,omitempty"`
+
+	// AutomaticRollback is set while a revision that failed its health checks
+	// is rolled back automatically.
+	// +optional
+	AutomaticRollback *AutomaticRollback `json:"automaticRollback,omitempty"`
+
+	// LastHandledReconcileAt is the last value of the
+	// `configs.example.io/reconcile-at` annotation that was acted upon.
+	// +optional
+	LastHandledReconcileAt string `json:"lastHandledReconcileAt,omitempty"`
+
 	// Conditions represent the current state of the ConfigSync resource.
 	// This follows the Kubernetes condition convention (type, status, reason,
 	// message, lastTransitionTime).
@@ -0,0 +1,99 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Git providers whose push events a Receiver accepts.
+const (
+	ReceiverTypeGitHub    = "github"
+	ReceiverTypeGitLab    = "gitlab"
+	ReceiverTypeGitea     = "gitea"
+	ReceiverTypeBitbucket = "bitbucket"
+)
+
+// ReceiverSpec defines the desired state of Receiver
+type ReceiverSpec struct {
+	// Type is the Git provider sending the push events. It selects how the
+	// payload is authenticated and parsed.
+	// +kubebuilder:validation:Enum=github;gitlab;gitea;bitbucket
+	Type string `json:"type"`
+
+	// SecretRef references a Secret in the Receiver's namespace whose `token`
+	// key holds the webhook secret configured at the provider. GitHub, Gitea
+	// and Bitbucket sign payloads with it; GitLab sends it as is.
+	SecretRef LocalObjectReference `json:"secretRef"`
+
+	// Selector limits the ConfigSyncs in the Receiver's namespace that push
+	// events can trigger. Defaults to all of them.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// ReceiverStatus defines the observed state of Receiver.
+type ReceiverStatus struct {
+	// LastEventTime is when the last authenticated push event was received.
+	// +optional
+	LastEventTime *metav1.Time `json:"lastEventTime,omitempty"`
+
+	// LastEventRef is the ref (for example `refs/heads/main`) pushed by the
+	// last authenticated event.
+	// +optional
+	LastEventRef string `json:"lastEventRef,omitempty"`
+
+	// Triggered lists the ConfigSyncs that the last event triggered.
+	// +optional
+	Triggered []string `json:"triggered,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// Receiver is the Schema for the receivers API. It accepts push events from
+// a Git provider at `/hook/<namespace>/<name>` on the operator's webhook
+// server and immediately reconciles the ConfigSyncs syncing the pushed
+// repository and branch, so they need not poll frequently.
+type Receiver struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// spec defines the desired state of Receiver
+	// +required
+	Spec ReceiverSpec `json:"spec"`
+
+	// status defines the observed state of Receiver
+	// +optional
+	Status ReceiverStatus `json:"status,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// ReceiverList contains a list of Receiver
+type ReceiverList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitzero"`
+	Items           []Receiver `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Receiver{}, &ReceiverList{})
+}
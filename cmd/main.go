@@ -20,15 +20,20 @@ import (
 	"crypto/tls"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -37,6 +42,8 @@ import (
 
 	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
 	"github.com/joe-bresee/config-synchronizer-operator/internal/controller"
+	"github.com/joe-bresee/config-synchronizer-operator/internal/receiver"
+	source "github.com/joe-bresee/config-synchronizer-operator/internal/sources"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -61,6 +68,10 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var knownHostsConfigMap string
+	var sourceCacheDir, sourceCacheMaxSize string
+	var sourceCacheTTL time.Duration
+	var enableReceivers bool
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -79,6 +90,19 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&knownHostsConfigMap, "known-hosts-configmap", "",
+		"The <namespace>/<name> of a ConfigMap whose known_hosts key verifies SSH host keys "+
+			"for Git sources whose auth Secret carries none.")
+	flag.StringVar(&sourceCacheDir, "source-cache-dir", source.DefaultCacheRoot(),
+		"The directory fetched Git repositories and their checkouts are cached in.")
+	flag.DurationVar(&sourceCacheTTL, "source-cache-ttl", 24*time.Hour,
+		"Evict cached repositories and checkouts that were not used for this long. 0 disables TTL eviction.")
+	flag.StringVar(&sourceCacheMaxSize, "source-cache-max-size", "",
+		"Evict the least recently used cache entries while the source cache is larger than this quantity "+
+			"(for example 10Gi). Leave empty for no limit.")
+	flag.BoolVar(&enableReceivers, "enable-receivers", false,
+		"If set, the webhook server accepts Git push events for Receivers at /hook/<namespace>/<name>. "+
+			"The webhook server requires a serving certificate, see --webhook-cert-path.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -178,9 +202,56 @@ func main() {
 		os.Exit(1)
 	}
 
+	var cacheMaxSize int64
+	if sourceCacheMaxSize != "" {
+		quantity, err := resource.ParseQuantity(sourceCacheMaxSize)
+		if err != nil {
+			setupLog.Error(err, "invalid --source-cache-max-size", "value", sourceCacheMaxSize)
+			os.Exit(1)
+		}
+		cacheMaxSize = quantity.Value()
+	}
+	sourceCache, err := source.NewCache(source.CacheOptions{
+		Root:    sourceCacheDir,
+		TTL:     sourceCacheTTL,
+		MaxSize: cacheMaxSize,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to set up source cache")
+		os.Exit(1)
+	}
+	if err := mgr.Add(sourceCache); err != nil {
+		setupLog.Error(err, "unable to set up source cache eviction")
+		os.Exit(1)
+	}
+
+	sourceOptions := source.Options{Cache: sourceCache}
+	if knownHostsConfigMap != "" {
+		namespace, name, ok := strings.Cut(knownHostsConfigMap, "/")
+		if !ok || namespace == "" || name == "" {
+			setupLog.Error(nil, "--known-hosts-configmap must be of the form <namespace>/<name>", "value", knownHostsConfigMap)
+			os.Exit(1)
+		}
+		sourceOptions.KnownHosts = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+
+	var triggers chan event.GenericEvent
+	if enableReceivers {
+		triggers = make(chan event.GenericEvent, 100)
+		mgr.GetWebhookServer().Register(receiver.PathPrefix, &receiver.Handler{
+			Client:  mgr.GetClient(),
+			Trigger: triggers,
+			Elected: mgr.Elected(),
+		})
+	}
+
 	if err := (&controller.ConfigSyncReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Config:        mgr.GetConfig(),
+		SourceOptions: sourceOptions,
+		Triggers:      triggers,
+		Recorder:      mgr.GetEventRecorderFor("configsync-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ConfigSync")
 		os.Exit(1)
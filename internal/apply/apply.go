@@ -4,93 +4,248 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/yaml"
 )
 
+// FieldManager is the server-side apply field owner used for every object the
+// operator applies.
+const FieldManager = "configsync"
+
 // DryRunEnabled controls whether ApplyTarget performs a server-side dry-run
 var DryRunEnabled = false
 
-func ApplyTarget(ctx context.Context, c client.Client, sourcePath string, target configsv1alpha1.TargetRef) error {
-	logger := log.FromContext(ctx)
+// FileRenderer transforms the raw content of a source file before it is
+// parsed, for example by executing it as a template. A nil FileRenderer leaves
+// files untouched.
+type FileRenderer func(path string, content []byte) ([]byte, error)
 
-	files, err := os.ReadDir(sourcePath)
-	if err != nil {
-		return fmt.Errorf("failed to list source directory: %w", err)
+// RenderTarget turns files into the objects desired for target without touching
+// the cluster. files is expected to be the selection returned by
+// source.CollectFiles, and every file is passed through render before use.
+//
+// For `ConfigMap` and `Secret` targets a single object named after the target
+// is generated from the files (see GenerateObject). For any other target type,
+// every YAML manifest among files is parsed as-is; files without a .yaml or
+// .yml extension are skipped.
+func RenderTarget(files []string, target configsv1alpha1.TargetRef, render FileRenderer) ([]*unstructured.Unstructured, error) {
+	switch target.Type {
+	case TargetTypeConfigMap, TargetTypeSecret:
+		obj, err := GenerateObject(files, target, render)
+		if err != nil {
+			return nil, err
+		}
+		return []*unstructured.Unstructured{obj}, nil
 	}
 
-	for _, file := range files {
-		if file.IsDir() || !(strings.HasSuffix(file.Name(), ".yaml") || strings.HasSuffix(file.Name(), ".yml")) {
+	var objs []*unstructured.Unstructured
+
+	for _, filePath := range files {
+		if !(strings.HasSuffix(filePath, ".yaml") || strings.HasSuffix(filePath, ".yml")) {
 			continue
 		}
 
-		filePath := filepath.Join(sourcePath, file.Name())
-		data, err := os.ReadFile(filePath)
+		data, err := readSourceFile(filePath, render)
 		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", filePath, err)
+			return nil, err
 		}
 
-		docs := strings.Split(string(data), "\n---")
-		for _, doc := range docs {
-			doc = strings.TrimSpace(doc)
-			if doc == "" {
-				continue
-			}
+		fileObjs, err := ParseManifests(data, filePath, target.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, fileObjs...)
+	}
 
-			obj := &unstructured.Unstructured{}
-			jsonData, err := yaml.YAMLToJSON([]byte(doc))
-			if err != nil {
-				return fmt.Errorf("failed to convert YAML to JSON in %s: %w", filePath, err)
-			}
-			if err := obj.UnmarshalJSON(jsonData); err != nil {
-				return fmt.Errorf("failed to unmarshal object in %s: %w", filePath, err)
-			}
+	return objs, nil
+}
 
-			if target.Namespace != "" {
-				obj.SetNamespace(target.Namespace)
-			}
+// ParseManifests parses a multi-document YAML stream into objects ready to be
+// applied. When namespace is set it overrides the namespace of every object.
+// origin describes where the stream came from and is used in error messages.
+func ParseManifests(data []byte, origin, namespace string) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+
+	docs := strings.Split(string(data), "\n---")
+	for _, doc := range docs {
+		doc = strings.TrimSpace(strings.TrimPrefix(doc, "---"))
+		if doc == "" {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		jsonData, err := yaml.YAMLToJSON([]byte(doc))
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert YAML to JSON in %s: %w", origin, err)
+		}
+		if err := obj.UnmarshalJSON(jsonData); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal object in %s: %w", origin, err)
+		}
 
-			// Clean metadata completely
-			cleanObjectForApply(obj)
+		if namespace != "" {
+			obj.SetNamespace(namespace)
+		}
 
-			// Log object before applying
-			logger.Info("Object before apply", "obj", obj.UnstructuredContent())
+		// Clean metadata completely
+		cleanObjectForApply(obj)
 
-			applyOpts := []client.PatchOption{client.ForceOwnership, client.FieldOwner("configsync")}
+		objs = append(objs, obj)
+	}
 
-			logger.Info("dryrun status", "enabled", DryRunEnabled)
+	return objs, nil
+}
 
-			if DryRunEnabled {
-				logger.Info("Performing dry-run apply")
-				if err := c.Patch(ctx, obj, client.Apply, append(applyOpts, client.DryRunAll)...); err != nil {
-					return fmt.Errorf("dry-run failed for %s from %s: %w",
-						obj.GetKind(), filePath, err)
-				}
-			} else {
-				if err := c.Patch(ctx, obj, client.Apply, applyOpts...); err != nil {
-					return fmt.Errorf("failed to apply %s from %s: %w",
-						obj.GetKind(), filePath, err)
+// readSourceFile reads filePath and passes its content through render.
+func readSourceFile(filePath string, render FileRenderer) ([]byte, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	if render == nil {
+		return data, nil
+	}
+	return render(filePath, data)
+}
+
+// crdEstablishedTimeout bounds the wait for applied CRDs to be served.
+const crdEstablishedTimeout = time.Minute
+
+// ApplyTarget server-side applies the rendered objects of one or more targets
+// in dependency order (see SortForApply) and returns a reference to each of
+// them. CustomResourceDefinitions are waited on until they are established
+// before any later object is applied, so custom resources defined in the
+// same source can be created right away.
+func ApplyTarget(ctx context.Context, c client.Client, objs []*unstructured.Unstructured) ([]configsv1alpha1.ResourceRef, error) {
+	objs, err := SortForApply(objs)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]configsv1alpha1.ResourceRef, 0, len(objs))
+	var pendingCRDs []string
+	for _, obj := range objs {
+		isCRD := obj.GroupVersionKind().GroupKind() == crdGroupKind
+		if !isCRD && len(pendingCRDs) > 0 {
+			if err := waitForCRDs(ctx, c, pendingCRDs); err != nil {
+				return nil, err
+			}
+			pendingCRDs = nil
+		}
+		if err := applyObject(ctx, c, obj.DeepCopy()); err != nil {
+			return nil, err
+		}
+		if isCRD && !DryRunEnabled {
+			pendingCRDs = append(pendingCRDs, obj.GetName())
+		}
+		applied = append(applied, RefForObject(obj))
+	}
+	return applied, nil
+}
+
+// crdGroupKind identifies CustomResourceDefinitions.
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// waitForCRDs waits until the named CustomResourceDefinitions report the
+// Established condition, then resets c's RESTMapper if it caches discovery
+// so that their kinds can be mapped.
+func waitForCRDs(ctx context.Context, c client.Client, names []string) error {
+	logger := log.FromContext(ctx)
+	for _, name := range names {
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(crdGroupKind.WithVersion("v1"))
+		err := wait.PollUntilContextTimeout(ctx, 250*time.Millisecond, crdEstablishedTimeout, true, func(ctx context.Context) (bool, error) {
+			if err := c.Get(ctx, client.ObjectKey{Name: name}, crd); err != nil {
+				return false, client.IgnoreNotFound(err)
+			}
+			conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+			for _, condition := range conditions {
+				cond, _ := condition.(map[string]interface{})
+				if cond["type"] == "Established" && cond["status"] == "True" {
+					return true, nil
 				}
 			}
+			return false, nil
+		})
+		if err != nil {
+			return fmt.Errorf("CustomResourceDefinition %s did not become established: %w", name, err)
+		}
+		logger.Info("CustomResourceDefinition established", "name", name)
+	}
+
+	if mapper, ok := c.RESTMapper().(meta.ResettableRESTMapper); ok {
+		mapper.Reset()
+	}
+	return nil
+}
+
+// applyObject server-side applies a single object with the configsync field owner.
+func applyObject(ctx context.Context, c client.Client, obj *unstructured.Unstructured) error {
+	logger := log.FromContext(ctx)
+
+	// Log object before applying, without the content of Secrets
+	logger.V(1).Info("Object before apply", "obj", redactSecret(obj))
 
-			logger.Info("Applied manifest",
-				"kind", obj.GetKind(),
-				"name", obj.GetName(),
-				"namespace", obj.GetNamespace(),
-				"file", filePath,
-			)
+	applyOpts := []client.PatchOption{client.ForceOwnership, client.FieldOwner(FieldManager)}
+
+	logger.Info("dryrun status", "enabled", DryRunEnabled)
+
+	if DryRunEnabled {
+		logger.Info("Performing dry-run apply")
+		if err := c.Patch(ctx, obj, client.Apply, append(applyOpts, client.DryRunAll)...); err != nil {
+			return fmt.Errorf("dry-run failed for %s %s: %w",
+				obj.GetKind(), objectKey(obj), err)
+		}
+	} else {
+		if err := c.Patch(ctx, obj, client.Apply, applyOpts...); err != nil {
+			return fmt.Errorf("failed to apply %s %s: %w",
+				obj.GetKind(), objectKey(obj), err)
 		}
 	}
 
+	logger.Info("Applied manifest",
+		"kind", obj.GetKind(),
+		"name", obj.GetName(),
+		"namespace", obj.GetNamespace(),
+	)
 	return nil
 }
 
+// redactSecret returns the content of obj for logging. The values of a
+// Secret's data and stringData are replaced, keeping only the keys.
+func redactSecret(obj *unstructured.Unstructured) map[string]interface{} {
+	if obj.GroupVersionKind().GroupKind() != (schema.GroupKind{Kind: "Secret"}) {
+		return obj.UnstructuredContent()
+	}
+	content := obj.DeepCopy().UnstructuredContent()
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := content[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key := range values {
+			values[key] = "<redacted>"
+		}
+	}
+	return content
+}
+
+// objectKey formats the namespace/name of obj for messages.
+func objectKey(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
 // cleanObjectForApply removes all server-populated metadata fields to avoid managedFields errors
 func cleanObjectForApply(obj *unstructured.Unstructured) {
 	if obj == nil {
@@ -102,20 +257,20 @@ func cleanObjectForApply(obj *unstructured.Unstructured) {
 	// Remove status if present
 	delete(content, "status")
 
-	// Rebuild metadata with only safe fields
-	meta := map[string]interface{}{
+	// Rebuild metadata with only safe fields. Labels and annotations go
+	// through their setters, which store them as map[string]interface{} so
+	// that the object can still be deep-copied.
+	labels, annotations := obj.GetLabels(), obj.GetAnnotations()
+	content["metadata"] = map[string]interface{}{
 		"name":      obj.GetName(),
 		"namespace": obj.GetNamespace(),
 	}
+	obj.SetUnstructuredContent(content)
 
-	if labels := obj.GetLabels(); len(labels) > 0 {
-		meta["labels"] = labels
+	if len(labels) > 0 {
+		obj.SetLabels(labels)
 	}
-	if annotations := obj.GetAnnotations(); len(annotations) > 0 {
-		meta["annotations"] = annotations
+	if len(annotations) > 0 {
+		obj.SetAnnotations(annotations)
 	}
-
-	content["metadata"] = meta
-
-	obj.SetUnstructuredContent(content)
 }
@@ -0,0 +1,28 @@
+package apply
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactSecret(t *testing.T) {
+	secret := fromYAML(t, `apiVersion: v1
+kind: Secret
+metadata: {name: creds}
+data: {password: aHVudGVyMg==}
+stringData: {token: s3cr3t}`)
+
+	got := redactSecret(secret)
+	want := map[string]interface{}{"password": "<redacted>"}
+	if !reflect.DeepEqual(got["data"], want) || !reflect.DeepEqual(got["stringData"], map[string]interface{}{"token": "<redacted>"}) {
+		t.Fatalf("expected the Secret values to be redacted, got %v", got)
+	}
+	if secret.Object["data"].(map[string]interface{})["password"] != "aHVudGVyMg==" {
+		t.Fatal("expected the applied Secret to be left untouched")
+	}
+
+	cm := fromYAML(t, "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: settings}\ndata: {mode: fast}")
+	if got := redactSecret(cm); got["data"].(map[string]interface{})["mode"] != "fast" {
+		t.Fatalf("expected ConfigMap data to be logged as is, got %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DetectDrift compares every desired object against its live counterpart and
+// returns the objects that are missing or differ. The desired state is computed
+// by a server-side dry-run apply, so defaulting and fields owned by other
+// managers do not count as drift.
+func DetectDrift(ctx context.Context, c client.Client, objs []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	logger := log.FromContext(ctx)
+
+	var drifted []*unstructured.Unstructured
+	for _, desired := range objs {
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(desired.GroupVersionKind())
+		err := c.Get(ctx, client.ObjectKeyFromObject(desired), live)
+		if apierrors.IsNotFound(err) {
+			logger.Info("Drift detected: object is missing", "kind", desired.GetKind(), "name", desired.GetName(), "namespace", desired.GetNamespace())
+			drifted = append(drifted, desired)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s %s: %w", desired.GetKind(), objectKey(desired), err)
+		}
+
+		preview := desired.DeepCopy()
+		err = c.Patch(ctx, preview, client.Apply, client.ForceOwnership, client.FieldOwner(FieldManager), client.DryRunAll)
+		if err != nil {
+			return nil, fmt.Errorf("dry-run failed for %s %s: %w", desired.GetKind(), objectKey(desired), err)
+		}
+
+		if hasDrifted(preview, live) {
+			logger.Info("Drift detected: object differs from source", "kind", desired.GetKind(), "name", desired.GetName(), "namespace", desired.GetNamespace())
+			drifted = append(drifted, desired)
+		}
+	}
+
+	return drifted, nil
+}
+
+// hasDrifted reports whether the dry-run result of applying the desired state
+// differs from the live object, ignoring bookkeeping fields the server updates
+// on its own.
+func hasDrifted(preview, live *unstructured.Unstructured) bool {
+	return !equality.Semantic.DeepEqual(comparableContent(preview), comparableContent(live))
+}
+
+func comparableContent(obj *unstructured.Unstructured) map[string]interface{} {
+	content := obj.DeepCopy().UnstructuredContent()
+	delete(content, "status")
+	unstructured.RemoveNestedField(content, "metadata", "managedFields")
+	unstructured.RemoveNestedField(content, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(content, "metadata", "generation")
+	return content
+}
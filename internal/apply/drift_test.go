@@ -0,0 +1,147 @@
+package apply
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func deployment(replicas int64, extraMeta map[string]interface{}) *unstructured.Unstructured {
+	meta := map[string]interface{}{"name": "web", "namespace": "default"}
+	for k, v := range extraMeta {
+		meta[k] = v
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   meta,
+		"spec":       map[string]interface{}{"replicas": replicas},
+	}}
+}
+
+func TestHasDrifted(t *testing.T) {
+	bookkeeping := map[string]interface{}{
+		"resourceVersion": "42",
+		"generation":      int64(3),
+		"managedFields":   []interface{}{map[string]interface{}{"manager": "kubectl"}},
+	}
+
+	tests := []struct {
+		name    string
+		preview *unstructured.Unstructured
+		live    *unstructured.Unstructured
+		want    bool
+	}{
+		{
+			name:    "identical",
+			preview: deployment(2, nil),
+			live:    deployment(2, nil),
+			want:    false,
+		},
+		{
+			name:    "server bookkeeping is ignored",
+			preview: deployment(2, nil),
+			live:    deployment(2, bookkeeping),
+			want:    false,
+		},
+		{
+			name:    "status is ignored",
+			preview: deployment(2, nil),
+			live: func() *unstructured.Unstructured {
+				d := deployment(2, nil)
+				d.Object["status"] = map[string]interface{}{"readyReplicas": int64(1)}
+				return d
+			}(),
+			want: false,
+		},
+		{
+			name:    "hand-edited spec",
+			preview: deployment(2, nil),
+			live:    deployment(5, nil),
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasDrifted(tt.preview, tt.live); got != tt.want {
+				t.Fatalf("hasDrifted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func configMapObject(data map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "app", "namespace": "default"},
+		"data":       data,
+	}}
+}
+
+// TestDetectDrift covers missing and hand-edited objects. The fake client's
+// dry-run apply does not merge fields owned by other managers, so that case is
+// exercised against a real API server in the controller suite.
+func TestDetectDrift(t *testing.T) {
+	ctx := context.Background()
+	desired := configMapObject(map[string]interface{}{"mode": "strict"})
+
+	tests := []struct {
+		name   string
+		mutate func(t *testing.T, c client.Client)
+		want   bool
+	}{
+		{
+			name: "live matches source",
+			want: false,
+		},
+		{
+			name: "missing object",
+			mutate: func(t *testing.T, c client.Client) {
+				if err := c.Delete(ctx, desired.DeepCopy()); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: true,
+		},
+		{
+			name: "hand-edited field",
+			mutate: func(t *testing.T, c client.Client) {
+				live := &corev1.ConfigMap{}
+				if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "app"}, live); err != nil {
+					t.Fatal(err)
+				}
+				live.Data["mode"] = "permissive"
+				if err := c.Update(ctx, live, client.FieldOwner("kubectl")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeClient()
+			if _, err := ApplyTarget(ctx, c, []*unstructured.Unstructured{desired.DeepCopy()}); err != nil {
+				t.Fatalf("apply failed: %v", err)
+			}
+			if tt.mutate != nil {
+				tt.mutate(t, c)
+			}
+
+			drifted, err := DetectDrift(ctx, c, []*unstructured.Unstructured{desired.DeepCopy()})
+			if err != nil {
+				t.Fatalf("drift detection failed: %v", err)
+			}
+			if got := len(drifted) == 1; got != tt.want {
+				t.Fatalf("drifted = %v, want drift %v", drifted, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package apply
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// Target types that are generated from source files rather than applied as manifests.
+const (
+	TargetTypeConfigMap = "ConfigMap"
+	TargetTypeSecret    = "Secret"
+)
+
+// GenerateObject builds the ConfigMap or Secret described by target from files.
+// Each file becomes one data key, named after the file unless target.Keys maps
+// it to another key, after being passed through render. For ConfigMaps, files
+// that are not valid UTF-8 are stored under binaryData.
+func GenerateObject(files []string, target configsv1alpha1.TargetRef, render FileRenderer) (*unstructured.Unstructured, error) {
+	if target.Name == "" {
+		return nil, fmt.Errorf("target of type %s requires a name", target.Type)
+	}
+
+	data, err := readDataKeys(files, target.Keys, render)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := metav1.ObjectMeta{Name: target.Name, Namespace: target.Namespace}
+
+	var obj runtime.Object
+	switch target.Type {
+	case TargetTypeConfigMap:
+		cm := &corev1.ConfigMap{ObjectMeta: meta}
+		cm.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+		for key, value := range data {
+			if utf8.Valid(value) {
+				if cm.Data == nil {
+					cm.Data = map[string]string{}
+				}
+				cm.Data[key] = string(value)
+			} else {
+				if cm.BinaryData == nil {
+					cm.BinaryData = map[string][]byte{}
+				}
+				cm.BinaryData[key] = value
+			}
+		}
+		obj = cm
+	case TargetTypeSecret:
+		secret := &corev1.Secret{ObjectMeta: meta, Type: corev1.SecretTypeOpaque, Data: data}
+		secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+		obj = secret
+	default:
+		return nil, fmt.Errorf("cannot generate an object for target type %s", target.Type)
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert generated %s: %w", target.Type, err)
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
+// readDataKeys reads every file into a map keyed by file name, applying the
+// key mappings. It rejects invalid keys and keys produced by more than one file.
+func readDataKeys(files []string, mappings []configsv1alpha1.KeyMapping, render FileRenderer) (map[string][]byte, error) {
+	renames := make(map[string]string, len(mappings))
+	for _, m := range mappings {
+		renames[m.File] = m.Key
+	}
+
+	data := make(map[string][]byte, len(files))
+	for _, filePath := range files {
+		name := filepath.Base(filePath)
+		key := name
+		if renamed, ok := renames[name]; ok {
+			key = renamed
+			delete(renames, name)
+		}
+
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid data key %q for file %s: %s", key, filePath, strings.Join(errs, "; "))
+		}
+		if _, exists := data[key]; exists {
+			return nil, fmt.Errorf("duplicate data key %q for file %s", key, filePath)
+		}
+
+		content, err := readSourceFile(filePath, render)
+		if err != nil {
+			return nil, err
+		}
+		data[key] = content
+	}
+
+	if len(renames) > 0 {
+		unmatched := make([]string, 0, len(renames))
+		for file := range renames {
+			unmatched = append(unmatched, file)
+		}
+		sort.Strings(unmatched)
+		return nil, fmt.Errorf("key mappings match no source file: %s", strings.Join(unmatched, ", "))
+	}
+
+	return data, nil
+}
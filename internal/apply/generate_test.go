@@ -0,0 +1,90 @@
+package apply
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+func writeFiles(t *testing.T, files map[string][]byte) []string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := make([]string, 0, len(files))
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func TestGenerateConfigMap(t *testing.T) {
+	files := writeFiles(t, map[string][]byte{
+		"app.properties": []byte("color=blue\n"),
+		"logo.png":       {0x89, 'P', 'N', 'G', 0xff, 0xfe},
+		"settings.json":  []byte(`{"debug":true}`),
+	})
+	target := configsv1alpha1.TargetRef{
+		Namespace: "apps",
+		Name:      "app-config",
+		Type:      TargetTypeConfigMap,
+		Keys:      []configsv1alpha1.KeyMapping{{File: "settings.json", Key: "config.json"}},
+	}
+
+	obj, err := GenerateObject(files, target, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if obj.GetKind() != "ConfigMap" || obj.GetName() != "app-config" || obj.GetNamespace() != "apps" {
+		t.Fatalf("unexpected object identity: %s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+	}
+	data, _, _ := unstructured.NestedStringMap(obj.Object, "data")
+	if data["app.properties"] != "color=blue\n" || data["config.json"] != `{"debug":true}` || len(data) != 2 {
+		t.Fatalf("unexpected data: %v", data)
+	}
+	if _, ok, _ := unstructured.NestedString(obj.Object, "binaryData", "logo.png"); !ok {
+		t.Fatalf("expected logo.png in binaryData, got %v", obj.Object["binaryData"])
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	files := writeFiles(t, map[string][]byte{"token": []byte("s3cr3t")})
+	target := configsv1alpha1.TargetRef{Namespace: "apps", Name: "creds", Type: TargetTypeSecret}
+
+	obj, err := GenerateObject(files, target, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if obj.GetKind() != "Secret" {
+		t.Fatalf("expected Secret, got %s", obj.GetKind())
+	}
+	// Secret data is base64 encoded in its unstructured form.
+	if got, _, _ := unstructured.NestedString(obj.Object, "data", "token"); got != "czNjcjN0" {
+		t.Fatalf("unexpected token data: %q", got)
+	}
+}
+
+func TestGenerateObjectErrors(t *testing.T) {
+	files := writeFiles(t, map[string][]byte{"a.txt": []byte("a"), "b.txt": []byte("b")})
+
+	tests := map[string]configsv1alpha1.TargetRef{
+		"missing name":     {Namespace: "apps", Type: TargetTypeConfigMap},
+		"duplicate key":    {Name: "x", Type: TargetTypeConfigMap, Keys: []configsv1alpha1.KeyMapping{{File: "a.txt", Key: "b.txt"}}},
+		"invalid key":      {Name: "x", Type: TargetTypeConfigMap, Keys: []configsv1alpha1.KeyMapping{{File: "a.txt", Key: "a/b"}}},
+		"unmatched rename": {Name: "x", Type: TargetTypeSecret, Keys: []configsv1alpha1.KeyMapping{{File: "c.txt", Key: "c"}}},
+	}
+	for name, target := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := GenerateObject(files, target, nil); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AssessHealth fetches every object referenced in refs and evaluates its
+// health. Objects that no longer exist are reported as NotFound.
+func AssessHealth(ctx context.Context, c client.Client, refs []configsv1alpha1.ResourceRef) ([]configsv1alpha1.ObjectHealth, error) {
+	out := make([]configsv1alpha1.ObjectHealth, 0, len(refs))
+	for _, ref := range refs {
+		obj, err := getLive(ctx, c, ref)
+		if err != nil {
+			return nil, err
+		}
+		health := configsv1alpha1.ObjectHealth{ResourceRef: ref, Status: configsv1alpha1.HealthNotFound}
+		if obj != nil {
+			health.Status, health.Message = Evaluate(obj)
+		}
+		out = append(out, health)
+	}
+	return out, nil
+}
+
+// Evaluate returns the health status of a live object and a message
+// describing it, following the conventions of kstatus. Workloads are judged by
+// their replica counts, Jobs by completion, PersistentVolumeClaims by binding
+// and LoadBalancer Services by their ingress address. Any other object is
+// judged by its `Stalled`, `Reconciling` and `Ready` conditions, and is
+// considered current when it has none.
+func Evaluate(obj *unstructured.Unstructured) (string, string) {
+	generation := obj.GetGeneration()
+	if observed, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); found && observed < generation {
+		return configsv1alpha1.HealthInProgress, fmt.Sprintf("Waiting for generation %d to be observed", generation)
+	}
+
+	switch obj.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Group: "apps", Kind: "Deployment"}:
+		return deploymentHealth(obj)
+	case schema.GroupKind{Group: "apps", Kind: "StatefulSet"}:
+		return statefulSetHealth(obj)
+	case schema.GroupKind{Group: "apps", Kind: "DaemonSet"}:
+		return daemonSetHealth(obj)
+	case schema.GroupKind{Group: "batch", Kind: "Job"}:
+		return jobHealth(obj)
+	case schema.GroupKind{Kind: "PersistentVolumeClaim"}:
+		return pvcHealth(obj)
+	case schema.GroupKind{Kind: "Service"}:
+		return serviceHealth(obj)
+	case schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:
+		return crdHealth(obj)
+	}
+	return conditionsHealth(obj)
+}
+
+func deploymentHealth(obj *unstructured.Unstructured) (string, string) {
+	if cond, ok := findCondition(obj, "Progressing"); ok && cond.reason == "ProgressDeadlineExceeded" {
+		return configsv1alpha1.HealthFailed, "Progress deadline exceeded: " + cond.message
+	}
+
+	replicas := specReplicas(obj)
+	updated := statusInt(obj, "updatedReplicas")
+	available := statusInt(obj, "availableReplicas")
+	switch {
+	case updated < replicas:
+		return configsv1alpha1.HealthInProgress, fmt.Sprintf("Updated %d/%d replicas", updated, replicas)
+	case statusInt(obj, "replicas") > updated:
+		return configsv1alpha1.HealthInProgress, fmt.Sprintf("Waiting for %d old replicas to terminate", statusInt(obj, "replicas")-updated)
+	case available < replicas:
+		return configsv1alpha1.HealthInProgress, fmt.Sprintf("Available %d/%d replicas", available, replicas)
+	}
+	return configsv1alpha1.HealthCurrent, fmt.Sprintf("Deployment is available with %d replicas", replicas)
+}
+
+func statefulSetHealth(obj *unstructured.Unstructured) (string, string) {
+	replicas := specReplicas(obj)
+	if ready := statusInt(obj, "readyReplicas"); ready < replicas {
+		return configsv1alpha1.HealthInProgress, fmt.Sprintf("Ready %d/%d replicas", ready, replicas)
+	}
+
+	if strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type"); strategy != "OnDelete" {
+		partition, _, _ := unstructured.NestedInt64(obj.Object, "spec", "updateStrategy", "rollingUpdate", "partition")
+		updated := statusInt(obj, "updatedReplicas")
+		if partition > 0 {
+			if updated < replicas-partition {
+				return configsv1alpha1.HealthInProgress, fmt.Sprintf("Updated %d/%d replicas above partition %d", updated, replicas-partition, partition)
+			}
+		} else if current, update := statusString(obj, "currentRevision"), statusString(obj, "updateRevision"); current != update {
+			return configsv1alpha1.HealthInProgress, fmt.Sprintf("Rolling out revision %s: updated %d/%d replicas", update, updated, replicas)
+		}
+	}
+	return configsv1alpha1.HealthCurrent, fmt.Sprintf("StatefulSet is ready with %d replicas", replicas)
+}
+
+func daemonSetHealth(obj *unstructured.Unstructured) (string, string) {
+	desired := statusInt(obj, "desiredNumberScheduled")
+	if updated := statusInt(obj, "updatedNumberScheduled"); updated < desired {
+		return configsv1alpha1.HealthInProgress, fmt.Sprintf("Updated %d/%d pods", updated, desired)
+	}
+	if available := statusInt(obj, "numberAvailable"); available < desired {
+		return configsv1alpha1.HealthInProgress, fmt.Sprintf("Available %d/%d pods", available, desired)
+	}
+	return configsv1alpha1.HealthCurrent, fmt.Sprintf("DaemonSet is available on %d nodes", desired)
+}
+
+// jobHealth only reports a Job as current once it completed, so that waiting
+// on a ConfigSync also waits for the Jobs it runs, such as migrations.
+func jobHealth(obj *unstructured.Unstructured) (string, string) {
+	if cond, ok := findCondition(obj, "Failed"); ok && cond.status == "True" {
+		return configsv1alpha1.HealthFailed, "Job failed: " + cond.message
+	}
+	if cond, ok := findCondition(obj, "Complete"); ok && cond.status == "True" {
+		return configsv1alpha1.HealthCurrent, "Job completed"
+	}
+	return configsv1alpha1.HealthInProgress, fmt.Sprintf("Job is running: %d active, %d succeeded",
+		statusInt(obj, "active"), statusInt(obj, "succeeded"))
+}
+
+func pvcHealth(obj *unstructured.Unstructured) (string, string) {
+	switch phase := statusString(obj, "phase"); phase {
+	case "Bound":
+		return configsv1alpha1.HealthCurrent, "PersistentVolumeClaim is bound"
+	case "Lost":
+		return configsv1alpha1.HealthFailed, "PersistentVolumeClaim lost its volume"
+	default:
+		return configsv1alpha1.HealthInProgress, fmt.Sprintf("PersistentVolumeClaim is %s", phase)
+	}
+}
+
+func serviceHealth(obj *unstructured.Unstructured) (string, string) {
+	if serviceType, _, _ := unstructured.NestedString(obj.Object, "spec", "type"); serviceType == "LoadBalancer" {
+		ingress, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+		if len(ingress) == 0 {
+			return configsv1alpha1.HealthInProgress, "Waiting for a load balancer address"
+		}
+	}
+	return configsv1alpha1.HealthCurrent, "Service is ready"
+}
+
+func crdHealth(obj *unstructured.Unstructured) (string, string) {
+	if cond, ok := findCondition(obj, "NamesAccepted"); ok && cond.status == "False" {
+		return configsv1alpha1.HealthFailed, "Names not accepted: " + cond.message
+	}
+	if cond, ok := findCondition(obj, "Established"); ok && cond.status == "True" {
+		return configsv1alpha1.HealthCurrent, "CustomResourceDefinition is established"
+	}
+	return configsv1alpha1.HealthInProgress, "Waiting for the CustomResourceDefinition to be established"
+}
+
+func conditionsHealth(obj *unstructured.Unstructured) (string, string) {
+	if cond, ok := findCondition(obj, "Stalled"); ok && cond.status == "True" {
+		return configsv1alpha1.HealthFailed, cond.message
+	}
+	if cond, ok := findCondition(obj, "Reconciling"); ok && cond.status == "True" {
+		return configsv1alpha1.HealthInProgress, cond.message
+	}
+	if cond, ok := findCondition(obj, "Ready"); ok {
+		if cond.status == "True" {
+			return configsv1alpha1.HealthCurrent, cond.message
+		}
+		return configsv1alpha1.HealthInProgress, cond.message
+	}
+	return configsv1alpha1.HealthCurrent, ""
+}
+
+type condition struct {
+	status, reason, message string
+}
+
+// findCondition returns the status.conditions entry of type conditionType.
+func findCondition(obj *unstructured.Unstructured, conditionType string) (condition, bool) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["type"] != conditionType {
+			continue
+		}
+		status, _ := m["status"].(string)
+		reason, _ := m["reason"].(string)
+		message, _ := m["message"].(string)
+		return condition{status: status, reason: reason, message: message}, true
+	}
+	return condition{}, false
+}
+
+// specReplicas returns spec.replicas, which defaults to 1.
+func specReplicas(obj *unstructured.Unstructured) int64 {
+	if replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); found {
+		return replicas
+	}
+	return 1
+}
+
+func statusInt(obj *unstructured.Unstructured, field string) int64 {
+	value, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+	return value
+}
+
+func statusString(obj *unstructured.Unstructured, field string) string {
+	value, _, _ := unstructured.NestedString(obj.Object, "status", field)
+	return value
+}
@@ -0,0 +1,196 @@
+package apply
+
+import (
+	"context"
+	"testing"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func fromYAML(t *testing.T, manifest string) *unstructured.Unstructured {
+	t.Helper()
+	data, err := yaml.YAMLToJSON([]byte(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     string
+	}{
+		{
+			name: "available deployment",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web, generation: 2}
+spec: {replicas: 2}
+status: {observedGeneration: 2, replicas: 2, updatedReplicas: 2, availableReplicas: 2}`,
+			want: configsv1alpha1.HealthCurrent,
+		},
+		{
+			name: "unobserved generation",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web, generation: 3}
+spec: {replicas: 2}
+status: {observedGeneration: 2, replicas: 2, updatedReplicas: 2, availableReplicas: 2}`,
+			want: configsv1alpha1.HealthInProgress,
+		},
+		{
+			name: "crash-looping deployment",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec: {replicas: 2}
+status: {replicas: 2, updatedReplicas: 2, availableReplicas: 0}`,
+			want: configsv1alpha1.HealthInProgress,
+		},
+		{
+			name: "old replicas terminating",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+status: {replicas: 2, updatedReplicas: 1, availableReplicas: 1}`,
+			want: configsv1alpha1.HealthInProgress,
+		},
+		{
+			name: "deployment past its progress deadline",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+status:
+  conditions:
+  - {type: Progressing, status: "False", reason: ProgressDeadlineExceeded}`,
+			want: configsv1alpha1.HealthFailed,
+		},
+		{
+			name: "statefulset rolling out",
+			manifest: `apiVersion: apps/v1
+kind: StatefulSet
+metadata: {name: db}
+spec: {replicas: 1}
+status: {readyReplicas: 1, updatedReplicas: 0, currentRevision: db-1, updateRevision: db-2}`,
+			want: configsv1alpha1.HealthInProgress,
+		},
+		{
+			name: "statefulset updated above partition",
+			manifest: `apiVersion: apps/v1
+kind: StatefulSet
+metadata: {name: db}
+spec: {replicas: 3, updateStrategy: {type: RollingUpdate, rollingUpdate: {partition: 2}}}
+status: {readyReplicas: 3, updatedReplicas: 1, currentRevision: db-1, updateRevision: db-2}`,
+			want: configsv1alpha1.HealthCurrent,
+		},
+		{
+			name: "daemonset not available everywhere",
+			manifest: `apiVersion: apps/v1
+kind: DaemonSet
+metadata: {name: agent}
+status: {desiredNumberScheduled: 3, updatedNumberScheduled: 3, numberAvailable: 2}`,
+			want: configsv1alpha1.HealthInProgress,
+		},
+		{
+			name: "running job",
+			manifest: `apiVersion: batch/v1
+kind: Job
+metadata: {name: migrate}
+status: {active: 1}`,
+			want: configsv1alpha1.HealthInProgress,
+		},
+		{
+			name: "failed job",
+			manifest: `apiVersion: batch/v1
+kind: Job
+metadata: {name: migrate}
+status:
+  conditions:
+  - {type: Failed, status: "True", message: BackoffLimitExceeded}`,
+			want: configsv1alpha1.HealthFailed,
+		},
+		{
+			name: "pending claim",
+			manifest: `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata: {name: data}
+status: {phase: Pending}`,
+			want: configsv1alpha1.HealthInProgress,
+		},
+		{
+			name: "load balancer without address",
+			manifest: `apiVersion: v1
+kind: Service
+metadata: {name: web}
+spec: {type: LoadBalancer}`,
+			want: configsv1alpha1.HealthInProgress,
+		},
+		{
+			name: "cluster IP service",
+			manifest: `apiVersion: v1
+kind: Service
+metadata: {name: web}
+spec: {type: ClusterIP}`,
+			want: configsv1alpha1.HealthCurrent,
+		},
+		{
+			name: "stalled custom resource",
+			manifest: `apiVersion: example.io/v1
+kind: Widget
+metadata: {name: sprocket}
+status:
+  conditions:
+  - {type: Ready, status: "False"}
+  - {type: Stalled, status: "True", message: invalid spec}`,
+			want: configsv1alpha1.HealthFailed,
+		},
+		{
+			name: "custom resource not ready",
+			manifest: `apiVersion: example.io/v1
+kind: Widget
+metadata: {name: sprocket}
+status:
+  conditions:
+  - {type: Ready, status: "False"}`,
+			want: configsv1alpha1.HealthInProgress,
+		},
+		{
+			name: "object without status",
+			manifest: `apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings}`,
+			want: configsv1alpha1.HealthCurrent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, message := Evaluate(fromYAML(t, tt.manifest)); got != tt.want {
+				t.Fatalf("expected %s, got %s (%s)", tt.want, got, message)
+			}
+		})
+	}
+}
+
+func TestAssessHealth(t *testing.T) {
+	c := newFakeClient(configMap("present", "", nil))
+	refs := []configsv1alpha1.ResourceRef{
+		{Kind: "ConfigMap", Namespace: "default", Name: "present"},
+		{Kind: "ConfigMap", Namespace: "default", Name: "missing"},
+	}
+
+	got, err := AssessHealth(context.Background(), c, refs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].Status != configsv1alpha1.HealthCurrent || got[1].Status != configsv1alpha1.HealthNotFound {
+		t.Fatalf("expected the missing object to be reported as NotFound, got %+v", got)
+	}
+}
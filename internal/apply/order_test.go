@@ -0,0 +1,107 @@
+package apply
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// object returns an object of kind named name in namespace with annotations.
+func object(kind, namespace, name string, annotations map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+func names(objs []*unstructured.Unstructured) []string {
+	out := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		out = append(out, obj.GetName())
+	}
+	return out
+}
+
+func TestSortForApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		objs    []*unstructured.Unstructured
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "kind phases",
+			objs: []*unstructured.Unstructured{
+				object("ValidatingWebhookConfiguration", "", "webhook", nil),
+				object("Widget", "apps", "widget", nil),
+				object("Deployment", "apps", "deploy", nil),
+				object("ConfigMap", "apps", "config", nil),
+				object("RoleBinding", "apps", "binding", nil),
+				object("CustomResourceDefinition", "", "crd", nil),
+				object("Namespace", "", "apps", nil),
+			},
+			want: []string{"apps", "crd", "binding", "config", "widget", "deploy", "webhook"},
+		},
+		{
+			name: "apply-order waves",
+			objs: []*unstructured.Unstructured{
+				object("Deployment", "apps", "late", map[string]string{ApplyOrderAnnotation: "1"}),
+				object("Deployment", "apps", "deploy", nil),
+				object("Job", "apps", "migrate", map[string]string{ApplyOrderAnnotation: "-1"}),
+				object("Namespace", "", "apps", map[string]string{ApplyOrderAnnotation: "-1"}),
+			},
+			want: []string{"apps", "migrate", "deploy", "late"},
+		},
+		{
+			name: "depends-on",
+			objs: []*unstructured.Unstructured{
+				object("Deployment", "apps", "frontend", map[string]string{DependsOnAnnotation: "Deployment/backend, Job/other/migrate"}),
+				object("Deployment", "apps", "backend", nil),
+				object("Service", "apps", "frontend", nil),
+				object("Job", "other", "migrate", nil),
+				object("Deployment", "apps", "standalone", map[string]string{DependsOnAnnotation: "Deployment/not-rendered"}),
+			},
+			want: []string{"backend", "frontend", "migrate", "frontend", "standalone"},
+		},
+		{
+			name: "cycle",
+			objs: []*unstructured.Unstructured{
+				object("Deployment", "apps", "a", map[string]string{DependsOnAnnotation: "Deployment/b"}),
+				object("Deployment", "apps", "b", map[string]string{DependsOnAnnotation: "Deployment/a"}),
+			},
+			wantErr: "dependency cycle",
+		},
+		{
+			name:    "malformed apply-order",
+			objs:    []*unstructured.Unstructured{object("Deployment", "apps", "a", map[string]string{ApplyOrderAnnotation: "first"})},
+			wantErr: ApplyOrderAnnotation,
+		},
+		{
+			name:    "malformed depends-on",
+			objs:    []*unstructured.Unstructured{object("Deployment", "apps", "a", map[string]string{DependsOnAnnotation: "backend"})},
+			wantErr: "malformed entry",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SortForApply(tt.objs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(names(got), tt.want) {
+				t.Fatalf("expected order %v, got %v", tt.want, names(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// PruneAnnotation opts a single object out of pruning when set to "disabled".
+const PruneAnnotation = "configs.example.io/prune"
+
+// RefForObject returns the inventory reference for obj.
+func RefForObject(obj *unstructured.Unstructured) configsv1alpha1.ResourceRef {
+	gvk := obj.GroupVersionKind()
+	return configsv1alpha1.ResourceRef{
+		Group:     gvk.Group,
+		Kind:      gvk.Kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+}
+
+// NormalizeInventory removes duplicate references and sorts them so the
+// inventory recorded in status is stable across reconciles.
+func NormalizeInventory(refs []configsv1alpha1.ResourceRef) []configsv1alpha1.ResourceRef {
+	seen := make(map[configsv1alpha1.ResourceRef]struct{}, len(refs))
+	out := make([]configsv1alpha1.ResourceRef, 0, len(refs))
+	for _, ref := range refs {
+		if _, ok := seen[ref]; ok {
+			continue
+		}
+		seen[ref] = struct{}{}
+		out = append(out, ref)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return out
+}
+
+// Prune deletes every object referenced in previous that is absent from current.
+// Objects that no longer exist, carry the prune opt-out annotation, or are no
+// longer managed by the configsync field manager are left alone. It returns the
+// references that were deleted.
+func Prune(ctx context.Context, c client.Client, previous, current []configsv1alpha1.ResourceRef) ([]configsv1alpha1.ResourceRef, error) {
+	logger := log.FromContext(ctx)
+
+	keep := make(map[configsv1alpha1.ResourceRef]struct{}, len(current))
+	for _, ref := range current {
+		keep[ref] = struct{}{}
+	}
+
+	var pruned []configsv1alpha1.ResourceRef
+	for _, ref := range previous {
+		if _, ok := keep[ref]; ok {
+			continue
+		}
+
+		obj, err := getLive(ctx, c, ref)
+		if err != nil {
+			return pruned, err
+		}
+		if obj == nil {
+			continue
+		}
+
+		if obj.GetAnnotations()[PruneAnnotation] == "disabled" {
+			logger.Info("Skipping prune of opted-out object", "kind", ref.Kind, "name", ref.Name, "namespace", ref.Namespace)
+			continue
+		}
+		if !managedByConfigSync(obj) {
+			logger.Info("Skipping prune of object no longer managed by configsync", "kind", ref.Kind, "name", ref.Name, "namespace", ref.Namespace)
+			continue
+		}
+
+		deleteOpts := []client.DeleteOption{client.PropagationPolicy(metav1.DeletePropagationBackground)}
+		if DryRunEnabled {
+			deleteOpts = append(deleteOpts, client.DryRunAll)
+		}
+		if err := c.Delete(ctx, obj, deleteOpts...); client.IgnoreNotFound(err) != nil {
+			return pruned, fmt.Errorf("failed to prune %s %s/%s: %w", ref.Kind, ref.Namespace, ref.Name, err)
+		}
+
+		logger.Info("Pruned object", "kind", ref.Kind, "name", ref.Name, "namespace", ref.Namespace)
+		pruned = append(pruned, ref)
+	}
+
+	return pruned, nil
+}
+
+// getLive fetches the object behind ref, resolving its preferred version via
+// the client's RESTMapper. It returns nil when the object or its kind no longer
+// exists.
+func getLive(ctx context.Context, c client.Client, ref configsv1alpha1.ResourceRef) (*unstructured.Unstructured, error) {
+	mapping, err := c.RESTMapper().RESTMapping(schema.GroupKind{Group: ref.Group, Kind: ref.Kind})
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to map %s.%s: %w", ref.Kind, ref.Group, err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(mapping.GroupVersionKind)
+	if err := c.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get %s %s/%s: %w", ref.Kind, ref.Namespace, ref.Name, err)
+	}
+	return obj, nil
+}
+
+// managedByConfigSync reports whether the configsync field manager still owns
+// any fields of obj.
+func managedByConfigSync(obj *unstructured.Unstructured) bool {
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == FieldManager {
+			return true
+		}
+	}
+	return false
+}
+
+// Orphan releases the configsync field manager's ownership of every referenced
+// object, leaving the objects and their contents in place so that other
+// managers (or users) can take them over.
+func Orphan(ctx context.Context, c client.Client, refs []configsv1alpha1.ResourceRef) error {
+	logger := log.FromContext(ctx)
+
+	for _, ref := range refs {
+		obj, err := getLive(ctx, c, ref)
+		if err != nil {
+			return err
+		}
+		if obj == nil || !managedByConfigSync(obj) {
+			continue
+		}
+
+		base := obj.DeepCopy()
+		var kept []metav1.ManagedFieldsEntry
+		for _, entry := range obj.GetManagedFields() {
+			if entry.Manager != FieldManager {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) == 0 {
+			// The API server ignores an empty list; a single empty entry clears managedFields.
+			kept = []metav1.ManagedFieldsEntry{{}}
+		}
+		obj.SetManagedFields(kept)
+
+		if err := c.Patch(ctx, obj, client.MergeFrom(base)); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to orphan %s %s/%s: %w", ref.Kind, ref.Namespace, ref.Name, err)
+		}
+
+		logger.Info("Orphaned object", "kind", ref.Kind, "name", ref.Name, "namespace", ref.Namespace)
+	}
+
+	return nil
+}
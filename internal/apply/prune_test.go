@@ -0,0 +1,120 @@
+package apply
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+func newFakeClient(objs ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(clientgoscheme.Scheme)).
+		WithObjects(objs...).
+		WithReturnManagedFields().
+		Build()
+}
+
+// configMap returns a ConfigMap in the default namespace, owned by manager
+// when it is non-empty.
+func configMap(name, manager string, annotations map[string]string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:        name,
+		Namespace:   "default",
+		Annotations: annotations,
+	}}
+	if manager != "" {
+		cm.ManagedFields = []metav1.ManagedFieldsEntry{{
+			Manager:    manager,
+			Operation:  metav1.ManagedFieldsOperationApply,
+			APIVersion: "v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:data":{}}`)},
+		}}
+	}
+	return cm
+}
+
+func cmRef(name string) configsv1alpha1.ResourceRef {
+	return configsv1alpha1.ResourceRef{Kind: "ConfigMap", Namespace: "default", Name: name}
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(
+		configMap("keep", FieldManager, nil),
+		configMap("removed", FieldManager, nil),
+		configMap("optout", FieldManager, map[string]string{PruneAnnotation: "disabled"}),
+		configMap("taken-over", "kubectl", nil),
+	)
+
+	previous := []configsv1alpha1.ResourceRef{
+		cmRef("keep"), cmRef("removed"), cmRef("optout"), cmRef("taken-over"), cmRef("already-gone"),
+	}
+	current := []configsv1alpha1.ResourceRef{cmRef("keep")}
+
+	pruned, err := Prune(ctx, c, previous, current)
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if want := []configsv1alpha1.ResourceRef{cmRef("removed")}; !reflect.DeepEqual(pruned, want) {
+		t.Fatalf("pruned %v, want %v", pruned, want)
+	}
+
+	for name, exists := range map[string]bool{"keep": true, "removed": false, "optout": true, "taken-over": true} {
+		err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &corev1.ConfigMap{})
+		if exists && err != nil {
+			t.Errorf("expected %s to exist: %v", name, err)
+		}
+		if !exists && !apierrors.IsNotFound(err) {
+			t.Errorf("expected %s to be deleted, got %v", name, err)
+		}
+	}
+}
+
+func TestNormalizeInventory(t *testing.T) {
+	refs := []configsv1alpha1.ResourceRef{
+		{Group: "apps", Kind: "Deployment", Namespace: "b", Name: "x"},
+		{Kind: "ConfigMap", Namespace: "a", Name: "y"},
+		{Group: "apps", Kind: "Deployment", Namespace: "b", Name: "x"},
+		{Kind: "ConfigMap", Namespace: "a", Name: "a"},
+	}
+	want := []configsv1alpha1.ResourceRef{
+		{Kind: "ConfigMap", Namespace: "a", Name: "a"},
+		{Kind: "ConfigMap", Namespace: "a", Name: "y"},
+		{Group: "apps", Kind: "Deployment", Namespace: "b", Name: "x"},
+	}
+	if got := NormalizeInventory(refs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestOrphan(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient(configMap("owned", FieldManager, nil))
+
+	if err := Orphan(ctx, c, []configsv1alpha1.ResourceRef{cmRef("owned"), cmRef("already-gone")}); err != nil {
+		t.Fatalf("orphan failed: %v", err)
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "owned"}, cm); err != nil {
+		t.Fatalf("expected orphaned object to remain: %v", err)
+	}
+	for _, entry := range cm.ManagedFields {
+		if entry.Manager == FieldManager {
+			t.Fatalf("expected %s ownership to be released, got %v", FieldManager, cm.ManagedFields)
+		}
+	}
+}
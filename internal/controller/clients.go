@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+const (
+	// reasonPermissionDenied is the condition reason used when the API server
+	// rejects a request for lack of RBAC permissions.
+	reasonPermissionDenied = "PermissionDenied"
+	// reasonRemoteClusterUnreachable is the condition reason used when the
+	// cluster referenced by spec.kubeConfig cannot be reached.
+	reasonRemoteClusterUnreachable = "RemoteClusterUnreachable"
+
+	// defaultKubeConfigKey is read from the kubeconfig Secret when it sets no key.
+	defaultKubeConfigKey = "value"
+)
+
+// applyClient returns the client used to apply, prune and read the objects
+// synced by configSync. It talks to the cluster in spec.kubeConfig when set and
+// to the local cluster otherwise. With spec.serviceAccountName set, its
+// requests impersonate that ServiceAccount so the ConfigSync is confined to the
+// ServiceAccount's RBAC permissions. Clients are cached per cluster and
+// ServiceAccount, and dropped once no ConfigSync uses them anymore.
+func (r *ConfigSyncReconciler) applyClient(ctx context.Context, configSync *configsv1alpha1.ConfigSync) (client.Client, error) {
+	name := configSync.Spec.ServiceAccountName
+	if name == "" && configSync.Spec.KubeConfig == nil {
+		return r.Client, nil
+	}
+
+	cfg := r.Config
+	var kubeConfig []byte
+	cacheKey := "local/" + name
+	if configSync.Spec.KubeConfig != nil {
+		var err error
+		if kubeConfig, err = r.readKubeConfig(ctx, configSync); err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(kubeConfig)
+		cacheKey = hex.EncodeToString(sum[:]) + "/" + name
+	}
+	user := client.ObjectKeyFromObject(configSync)
+	if c, ok := r.clusterClients.get(user, cacheKey); ok {
+		return c, nil
+	}
+
+	if kubeConfig != nil {
+		var err error
+		if cfg, err = restConfigFromKubeConfig(kubeConfig); err != nil {
+			return nil, err
+		}
+	}
+	if cfg == nil {
+		return nil, fmt.Errorf("no REST config available for ConfigSync %s/%s", configSync.Namespace, configSync.Name)
+	}
+
+	cfg = rest.CopyConfig(cfg)
+	opts := client.Options{Scheme: r.Scheme}
+	if kubeConfig == nil {
+		// the local cluster shares the manager's RESTMapper
+		opts.Mapper = r.RESTMapper()
+	}
+	if name != "" {
+		cfg.Impersonate = rest.ImpersonationConfig{
+			UserName: fmt.Sprintf("system:serviceaccount:%s:%s", configSync.Namespace, name),
+		}
+	}
+	c, err := client.New(cfg, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for ConfigSync %s/%s: %w", configSync.Namespace, configSync.Name, err)
+	}
+	return r.clusterClients.add(user, cacheKey, c), nil
+}
+
+// clientCache holds the clients built by applyClient, keyed by cluster and
+// ServiceAccount. It tracks the key each ConfigSync last used, so that a
+// client is dropped as soon as no ConfigSync uses it, for example after a
+// kubeconfig was rotated or the ConfigSync was deleted.
+type clientCache struct {
+	mu      sync.Mutex
+	clients map[string]client.Client
+	users   map[types.NamespacedName]string
+}
+
+// get returns the client cached under key and records that user now uses key.
+func (c *clientCache) get(user types.NamespacedName, key string) (client.Client, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cl, ok := c.clients[key]
+	if ok {
+		c.use(user, key)
+	}
+	return cl, ok
+}
+
+// add caches cl under key for user, unless a client was cached under key in
+// the meantime, and returns the cached client.
+func (c *clientCache) add(user types.NamespacedName, key string, cl client.Client) client.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.clients == nil {
+		c.clients = map[string]client.Client{}
+	}
+	if cached, ok := c.clients[key]; ok {
+		cl = cached
+	}
+	c.clients[key] = cl
+	c.use(user, key)
+	return cl
+}
+
+// release forgets the client used by user, dropping it when no other
+// ConfigSync uses it.
+func (c *clientCache) release(user types.NamespacedName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.use(user, "")
+}
+
+// use records that user now uses key, dropping the client it used before when
+// no other ConfigSync uses it. The caller must hold c.mu.
+func (c *clientCache) use(user types.NamespacedName, key string) {
+	if c.users == nil {
+		c.users = map[types.NamespacedName]string{}
+	}
+	previous, ok := c.users[user]
+	if key == "" {
+		delete(c.users, user)
+	} else {
+		c.users[user] = key
+	}
+	if !ok || previous == key {
+		return
+	}
+	for _, k := range c.users {
+		if k == previous {
+			return
+		}
+	}
+	delete(c.clients, previous)
+}
+
+// len returns the number of cached clients.
+func (c *clientCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.clients)
+}
+
+// readKubeConfig reads the kubeconfig referenced by spec.kubeConfig.
+func (r *ConfigSyncReconciler) readKubeConfig(ctx context.Context, configSync *configsv1alpha1.ConfigSync) ([]byte, error) {
+	ref := configSync.Spec.KubeConfig.SecretRef
+	key := ref.Key
+	if key == "" {
+		key = defaultKubeConfigKey
+	}
+
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: configSync.Namespace, Name: ref.Name}, &secret); err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig Secret %s/%s: %w", configSync.Namespace, ref.Name, err)
+	}
+	kubeConfig := secret.Data[key]
+	if len(kubeConfig) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no kubeconfig under key %q", configSync.Namespace, ref.Name, key)
+	}
+	return kubeConfig, nil
+}
+
+// restConfigFromKubeConfig parses a kubeconfig into a REST config. Because the
+// kubeconfig is supplied by users of the operator, anything that would run a
+// command or read a file of the operator's pod is rejected.
+func restConfigFromKubeConfig(kubeConfig []byte) (*rest.Config, error) {
+	cfg, err := clientcmd.Load(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+	for name, auth := range cfg.AuthInfos {
+		switch {
+		case auth.Exec != nil || auth.AuthProvider != nil:
+			return nil, fmt.Errorf("kubeconfig user %q: exec and auth-provider plugins are not supported", name)
+		case auth.TokenFile != "" || auth.ClientCertificate != "" || auth.ClientKey != "":
+			return nil, fmt.Errorf("kubeconfig user %q: credentials must be embedded, not read from files", name)
+		}
+	}
+	for name, cluster := range cfg.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return nil, fmt.Errorf("kubeconfig cluster %q: certificate authority must be embedded, not read from a file", name)
+		}
+	}
+
+	restConfig, err := clientcmd.NewDefaultClientConfig(*cfg, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %w", err)
+	}
+	return restConfig, nil
+}
+
+// failureReason returns the condition reason for err: reasonPermissionDenied
+// for RBAC denials, reasonRemoteClusterUnreachable for network errors talking
+// to a remote cluster, and reason otherwise.
+func failureReason(configSync *configsv1alpha1.ConfigSync, err error, reason string) string {
+	var netErr net.Error
+	switch {
+	case apierrors.IsForbidden(err):
+		return reasonPermissionDenied
+	case configSync.Spec.KubeConfig != nil && errors.As(err, &netErr):
+		return reasonRemoteClusterUnreachable
+	}
+	return reason
+}
+
+// clientError wraps a failure to build the client for a ConfigSync's objects.
+type clientError struct {
+	err error
+}
+
+func (e *clientError) Error() string { return e.err.Error() }
+func (e *clientError) Unwrap() error { return e.err }
+
+// unreachable reports whether err means the objects of configSync can't be
+// reached at all: their client can't be built, for example because the
+// kubeconfig Secret is gone, their cluster doesn't answer, or the
+// ServiceAccount it impersonates lost its permissions on them.
+func unreachable(configSync *configsv1alpha1.ConfigSync, err error) bool {
+	var clientErr *clientError
+	if errors.As(err, &clientErr) {
+		return true
+	}
+	reason := failureReason(configSync, err, "")
+	return reason == reasonRemoteClusterUnreachable || reason == reasonPermissionDenied
+}
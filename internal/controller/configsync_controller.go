@@ -18,33 +18,76 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	ctrlsource "sigs.k8s.io/controller-runtime/pkg/source"
 
 	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
 	apply "github.com/joe-bresee/config-synchronizer-operator/internal/apply"
 	source "github.com/joe-bresee/config-synchronizer-operator/internal/sources"
 )
 
+// configSyncFinalizer guards cleanup of applied objects and cached sources.
+const configSyncFinalizer = "configs.example.io/finalizer"
+
+const (
+	// healthPollInterval is how often applied objects are re-checked while
+	// spec.wait is waiting for them to become healthy.
+	healthPollInterval = 5 * time.Second
+	// defaultWaitTimeout applies when spec.wait.timeout is unset.
+	defaultWaitTimeout = 5 * time.Minute
+)
+
 // ConfigSyncReconciler reconciles a ConfigSync object
 type ConfigSyncReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Config is used to build clients impersonating spec.serviceAccountName.
+	Config *rest.Config
+
+	// SourceOptions carry operator-wide settings for fetching sources.
+	SourceOptions source.Options
+
+	// Triggers, when set, receives ConfigSyncs to reconcile right away, such
+	// as those matched by a Receiver's push event.
+	Triggers <-chan event.GenericEvent
+
+	// Recorder, when set, records events about ConfigSyncs.
+	Recorder record.EventRecorder
+
+	clusterClients clientCache
 }
 
 // +kubebuilder:rbac:groups=configs.example.io,resources=configsyncs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=configs.example.io,resources=configsyncs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=configs.example.io,resources=configsyncs/finalizers,verbs=update
 
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=impersonate
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -64,15 +107,82 @@ func (r *ConfigSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Clean up on deletion, otherwise make sure our finalizer is registered
+	if !configSync.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &configSync)
+	}
+	if controllerutil.AddFinalizer(&configSync, configSyncFinalizer) {
+		if err := r.Update(ctx, &configSync); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// A suspended ConfigSync is only synced on request
+	requested := configSync.Annotations[configsv1alpha1.ReconcileRequestAnnotation]
+	manual := requested != "" && requested != configSync.Status.LastHandledReconcileAt
+	if configSync.Spec.Suspend {
+		setCondition(&configSync.Status, "Suspended", metav1.ConditionTrue, "Suspended", "Synchronization is suspended")
+		if !manual {
+			log.Info("Synchronization is suspended")
+			return ctrl.Result{}, r.Status().Update(ctx, &configSync)
+		}
+		log.Info("Running a requested sync while suspended", "requestedAt", requested)
+	} else {
+		meta.RemoveStatusCondition(&configSync.Status.Conditions, "Suspended")
+	}
+
+	// Hold off until the ConfigSyncs this one depends on are ready
+	if len(configSync.Spec.DependsOn) > 0 {
+		notReady, err := r.checkDependencies(ctx, &configSync)
+		switch {
+		case errors.Is(err, errDependencyCycle):
+			markDegraded(&configSync.Status, "DependencyCycle", err.Error())
+			return ctrl.Result{}, r.Status().Update(ctx, &configSync)
+		case err != nil:
+			return ctrl.Result{}, err
+		case notReady != "":
+			log.Info("Waiting for dependencies", "reason", notReady)
+			setCondition(&configSync.Status, "Ready", metav1.ConditionFalse, "DependencyNotReady", notReady)
+			if err := r.Status().Update(ctx, &configSync); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: dependencyRequeueInterval}, nil
+		}
+	}
+
+	// The request is handled once the sync runs, so that one that fails is
+	// not retried while suspended
+	if manual {
+		configSync.Status.LastHandledReconcileAt = requested
+	}
+
+	applyClient, err := r.applyClient(ctx, &configSync)
+	if err != nil {
+		markDegraded(&configSync.Status, "ClientSetupFailed", err.Error())
+		_ = r.Status().Update(ctx, &configSync)
+		return ctrl.Result{}, err
+	}
+
 	// --------------------------------------------------------------
 	// Step 1: Fetch source and determine revision
 	// --------------------------------------------------------------
-	revisionSHA, sourcePath, commitMsg, err := source.FetchSource(&configSync, ctx, r.Client)
+	fetched, err := r.fetchSource(ctx, &configSync)
 	if err != nil {
-		setCondition(&configSync.Status, "Degraded", metav1.ConditionTrue, "SourceFetchFailed", err.Error())
+		reason := "SourceFetchFailed"
+		switch {
+		case errors.Is(err, source.ErrVerificationFailed):
+			reason = "VerificationFailed"
+		case errors.Is(err, source.ErrRepositoryTooLarge):
+			reason = "RepositoryTooLarge"
+		case errors.Is(err, errRollbackFailed):
+			reason = "RollbackFailed"
+		}
+		markDegraded(&configSync.Status, reason, err.Error())
 		_ = r.Status().Update(ctx, &configSync)
 		return ctrl.Result{}, err
 	}
+	setRollbackCondition(&configSync)
+	revisionSHA, sourcePath, commitMsg := fetched.Revision, fetched.Path, fetched.Message
 
 	// Log the fetched commit message (if any)
 	if commitMsg != "" {
@@ -86,71 +196,402 @@ func (r *ConfigSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		defer os.RemoveAll(sourcePath)
 	}
 
+	// Resolve the source's path to the set of files to apply
+	selectedPath, include, exclude := source.Selection(&configSync.Spec.Source)
+	files, err := source.CollectFiles(sourcePath, selectedPath, include, exclude)
+	if err != nil {
+		markDegraded(&configSync.Status, "InvalidSourcePath", err.Error())
+		_ = r.Status().Update(ctx, &configSync)
+		return ctrl.Result{}, err
+	}
+
+	// Prepare the render stages once; they are shared by every target
+	renderTarget, digest, err := r.targetRenderer(ctx, &configSync, sourcePath, files, revisionSHA)
+	if err != nil {
+		markDegraded(&configSync.Status, "RenderFailed", err.Error())
+		_ = r.Status().Update(ctx, &configSync)
+		return ctrl.Result{}, err
+	}
+
 	// --------------------------------------------------------------
 	// Step 2: Determine if we need to apply
 	// --------------------------------------------------------------
 	previousRevision := configSync.Status.SourceRevision
-	shouldApply := previousRevision != revisionSHA
+	shouldApply := previousRevision != revisionSHA ||
+		configSync.Status.ObservedGeneration != configSync.Generation ||
+		configSync.Status.RenderDigest != digest
 
 	if shouldApply {
-		log.Info("Source revision changed — applying", "old", previousRevision, "new", revisionSHA)
+		log.Info("Source revision or render inputs changed — applying", "old", previousRevision, "new", revisionSHA)
 
-		// Apply to all targets
+		// Render every target first so that all objects are applied in
+		// dependency order, whichever target or file they come from
+		var objs []*unstructured.Unstructured
 		for _, target := range configSync.Spec.Targets {
-			if err := apply.ApplyTarget(ctx, r.Client, sourcePath, target); err != nil {
-				setCondition(&configSync.Status, "Degraded", metav1.ConditionTrue, "ApplyFailed", err.Error())
+			targetObjs, err := renderTarget(target)
+			if err != nil {
+				markDegraded(&configSync.Status, "RenderFailed", err.Error())
 				_ = r.Status().Update(ctx, &configSync)
 				return ctrl.Result{}, err
 			}
+			objs = append(objs, targetObjs...)
 		}
+		applied, err := apply.ApplyTarget(ctx, applyClient, objs)
+		if err != nil {
+			recordSync(&configSync.Status, newSyncRecord(&configSync, fetched, len(objs), configsv1alpha1.SyncResultFailed))
+			markDegraded(&configSync.Status, failureReason(&configSync, err, "ApplyFailed"), err.Error())
+			_ = r.Status().Update(ctx, &configSync)
+			return ctrl.Result{}, err
+		}
+		inventory := apply.NormalizeInventory(applied)
+
+		// Prune objects that were removed from the source since the last revision
+		if configSync.Spec.Prune {
+			pruned, err := apply.Prune(ctx, applyClient, configSync.Status.Inventory, inventory)
+			if err != nil {
+				recordSync(&configSync.Status, newSyncRecord(&configSync, fetched, len(inventory), configsv1alpha1.SyncResultFailed))
+				markDegraded(&configSync.Status, failureReason(&configSync, err, "PruneFailed"), err.Error())
+				_ = r.Status().Update(ctx, &configSync)
+				return ctrl.Result{}, err
+			}
+			if len(pruned) > 0 {
+				log.Info("Pruned objects removed from source", "count", len(pruned))
+			}
+		}
+		configSync.Status.Inventory = inventory
+		configSync.Status.LastAppliedTime = &metav1.Time{Time: time.Now()}
+		recordSync(&configSync.Status, newSyncRecord(&configSync, fetched, len(inventory), configsv1alpha1.SyncResultApplied))
 
 		// Apply succeeded — mark condition
 		setCondition(&configSync.Status, "Degraded", metav1.ConditionFalse, "ApplySucceeded", "All targets applied successfully")
+		configSync.Status.Drifted = nil
+		setCondition(&configSync.Status, "Drifted", metav1.ConditionFalse, "NoDrift", "Applied objects match the source")
 	} else {
-		log.Info("No changes detected — skipping apply", "revision", revisionSHA)
+		log.Info("No changes detected — checking for drift", "revision", revisionSHA)
+
+		if err := r.checkDrift(ctx, applyClient, &configSync, renderTarget); err != nil {
+			markDegraded(&configSync.Status, failureReason(&configSync, err, "DriftCheckFailed"), err.Error())
+			_ = r.Status().Update(ctx, &configSync)
+			return ctrl.Result{}, err
+		}
 	}
 
 	// --------------------------------------------------------------
-	// Step 3: Always update status AFTER apply decision
+	// Step 3: Assess the health of the applied objects
+	// --------------------------------------------------------------
+	objectHealth, err := apply.AssessHealth(ctx, applyClient, configSync.Status.Inventory)
+	if err != nil {
+		markDegraded(&configSync.Status, failureReason(&configSync, err, "HealthAssessmentFailed"), err.Error())
+		_ = r.Status().Update(ctx, &configSync)
+		return ctrl.Result{}, err
+	}
+	configSync.Status.Health = objectHealth
+	waiting := setHealthConditions(&configSync, time.Now())
+
+	// --------------------------------------------------------------
+	// Step 4: Always update status AFTER apply decision
 	// --------------------------------------------------------------
 	configSync.Status.LastSyncedTime = &metav1.Time{Time: time.Now()}
 	configSync.Status.AppliedTargets = len(configSync.Spec.Targets)
 	configSync.Status.SourceRevision = revisionSHA
-	configSync.Status.SourcePath = sourcePath
+	configSync.Status.SourcePath = selectedPath
+	configSync.Status.SourceTag = fetched.Tag
+	configSync.Status.SourceSigner = fetched.Signer
+	configSync.Status.ObservedGeneration = configSync.Generation
+	configSync.Status.RenderDigest = digest
+	if recordHealth(&configSync) {
+		log.Info("Revision failed its health checks — rolling back",
+			"failed", revisionSHA, "revision", configSync.Status.AutomaticRollback.Revision)
+		waiting = true
+	}
 
 	if err := r.Status().Update(ctx, &configSync); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	// --------------------------------------------------------------
-	// Step 4: Compute requeue interval
+	// Step 5: Compute requeue interval
 	// --------------------------------------------------------------
 	requeueAfter := 30 * time.Second // default
 	if configSync.Spec.RefreshInterval != "" {
 		d, err := time.ParseDuration(configSync.Spec.RefreshInterval)
 		if err != nil {
-			setCondition(&configSync.Status, "Degraded", metav1.ConditionTrue, "InvalidRefreshInterval", "RefreshInterval must be a valid duration (e.g. 30s, 5m)")
+			markDegraded(&configSync.Status, "InvalidRefreshInterval", "RefreshInterval must be a valid duration (e.g. 30s, 5m)")
 			_ = r.Status().Update(ctx, &configSync)
 			return ctrl.Result{}, nil
 		}
 		requeueAfter = d
 	}
+	if waiting && requeueAfter > healthPollInterval {
+		requeueAfter = healthPollInterval
+	}
 
 	log.Info("Reconcile completed", "requeueAfter", requeueAfter.String())
 	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
+// setHealthConditions sets the Ready and Progressing conditions from
+// status.health: the ConfigSync is Ready once every applied object is
+// current. It returns true while spec.wait is waiting for objects to become
+// healthy, in which case they should be checked again shortly. A Job that
+// is gone after its revision became healthy was removed by its
+// ttlSecondsAfterFinished and counts as current.
+func setHealthConditions(configSync *configsv1alpha1.ConfigSync, now time.Time) bool {
+	status := &configSync.Status
+	healthy := len(status.History) > 0 && status.History[0].Revision == status.SourceRevision &&
+		status.History[0].Result == configsv1alpha1.SyncResultHealthy
+
+	var failed, progressing []string
+	for i, h := range status.Health {
+		if h.Status == configsv1alpha1.HealthNotFound && h.Group == "batch" && h.Kind == "Job" && healthy {
+			status.Health[i].Status = configsv1alpha1.HealthCurrent
+			status.Health[i].Message = "Job completed and was removed"
+			continue
+		}
+		name := h.Kind + " " + h.Name
+		if h.Namespace != "" {
+			name = h.Kind + " " + h.Namespace + "/" + h.Name
+		}
+		switch h.Status {
+		case configsv1alpha1.HealthFailed:
+			failed = append(failed, name)
+		case configsv1alpha1.HealthInProgress, configsv1alpha1.HealthNotFound:
+			progressing = append(progressing, name)
+		}
+	}
+
+	switch {
+	case len(failed) > 0:
+		message := fmt.Sprintf("%d object(s) failed: %s", len(failed), strings.Join(failed, ", "))
+		setCondition(status, "Progressing", metav1.ConditionFalse, "HealthCheckFailed", message)
+		setCondition(status, "Ready", metav1.ConditionFalse, "HealthCheckFailed", message)
+		return false
+	case len(progressing) > 0:
+		message := fmt.Sprintf("Waiting for %d object(s) to become healthy: %s", len(progressing), strings.Join(progressing, ", "))
+		setCondition(status, "Progressing", metav1.ConditionTrue, "Progressing", message)
+		wait := configSync.Spec.Wait
+		if wait == nil {
+			setCondition(status, "Ready", metav1.ConditionFalse, "Progressing", message)
+			return false
+		}
+		timeout := wait.Timeout.Duration
+		if timeout == 0 {
+			timeout = defaultWaitTimeout
+		}
+		if status.LastAppliedTime != nil && now.Sub(status.LastAppliedTime.Time) > timeout {
+			setCondition(status, "Ready", metav1.ConditionFalse, "HealthCheckTimeout",
+				fmt.Sprintf("Objects not healthy %s after the last apply. %s", timeout, message))
+			return false
+		}
+		setCondition(status, "Ready", metav1.ConditionFalse, "Progressing", message)
+		return true
+	}
+
+	setCondition(status, "Progressing", metav1.ConditionFalse, "Healthy", "All applied objects are healthy")
+	setCondition(status, "Ready", metav1.ConditionTrue, "Healthy", "All applied objects are healthy")
+	return false
+}
+
+// checkDrift compares the live objects against the rendered targets and
+// re-applies the drifted ones, adding them to the inventory in case they had to
+// be re-created. When spec.driftPolicy is Report they are only recorded in
+// status instead.
+func (r *ConfigSyncReconciler) checkDrift(
+	ctx context.Context,
+	c client.Client,
+	configSync *configsv1alpha1.ConfigSync,
+	renderTarget targetRenderer,
+) error {
+	log := logf.FromContext(ctx)
+
+	var drifted []*unstructured.Unstructured
+	for _, target := range configSync.Spec.Targets {
+		objs, err := renderTarget(target)
+		if err != nil {
+			return err
+		}
+		targetDrift, err := apply.DetectDrift(ctx, c, objs)
+		if err != nil {
+			return err
+		}
+		drifted = append(drifted, targetDrift...)
+	}
+
+	switch {
+	case len(drifted) == 0:
+		configSync.Status.Drifted = nil
+		setCondition(&configSync.Status, "Drifted", metav1.ConditionFalse, "NoDrift", "Applied objects match the source")
+	case configSync.Spec.DriftPolicy == configsv1alpha1.DriftPolicyReport:
+		log.Info("Drift detected — reporting only", "count", len(drifted))
+		refs := make([]configsv1alpha1.ResourceRef, 0, len(drifted))
+		for _, obj := range drifted {
+			refs = append(refs, apply.RefForObject(obj))
+		}
+		configSync.Status.Drifted = apply.NormalizeInventory(refs)
+		setCondition(&configSync.Status, "Drifted", metav1.ConditionTrue, "DriftDetected",
+			fmt.Sprintf("%d object(s) differ from the source", len(drifted)))
+	default:
+		log.Info("Drift detected — re-applying", "count", len(drifted))
+		applied, err := apply.ApplyTarget(ctx, c, drifted)
+		if err != nil {
+			return err
+		}
+		configSync.Status.Inventory = apply.NormalizeInventory(append(configSync.Status.Inventory, applied...))
+		configSync.Status.Drifted = nil
+		setCondition(&configSync.Status, "Drifted", metav1.ConditionFalse, "DriftCorrected",
+			fmt.Sprintf("Re-applied %d object(s) that differed from the source", len(drifted)))
+	}
+
+	return nil
+}
+
+// finalize cleans up after a ConfigSync that is being deleted: applied objects
+// are deleted or orphaned according to spec.deletionPolicy, the cached clone is
+// dropped once no other ConfigSync uses the repository, and the finalizer is
+// removed. Orphaning is best effort: when the cluster holding the objects
+// can't be reached or the ServiceAccount may no longer access them, a
+// CleanupFailed event is recorded and the objects are left as they are. With the skip-cleanup annotation, the objects are not touched.
+func (r *ConfigSyncReconciler) finalize(ctx context.Context, configSync *configsv1alpha1.ConfigSync) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(configSync, configSyncFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.cleanup(ctx, configSync); err != nil {
+		if configSync.Spec.DeletionPolicy == configsv1alpha1.DeletionPolicyDelete || !unreachable(configSync, err) {
+			markDegraded(&configSync.Status, failureReason(configSync, err, "CleanupFailed"),
+				fmt.Sprintf("%s; set the %s annotation to \"true\" to delete the ConfigSync without cleaning up",
+					err, configsv1alpha1.SkipCleanupAnnotation))
+			_ = r.Status().Update(ctx, configSync)
+			return ctrl.Result{}, err
+		}
+		log.Error(err, "failed to orphan applied objects; leaving them untouched")
+		r.event(configSync, corev1.EventTypeWarning, "CleanupFailed",
+			fmt.Sprintf("Left %d applied object(s) untouched: %v", len(configSync.Status.Inventory), err))
+	}
+
+	if err := r.releaseCache(ctx, configSync); err != nil {
+		// A stale cache is harmless; don't block deletion on it
+		log.Error(err, "failed to release cached source")
+	}
+	r.clusterClients.release(client.ObjectKeyFromObject(configSync))
+
+	controllerutil.RemoveFinalizer(configSync, configSyncFinalizer)
+	if err := r.Update(ctx, configSync); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// cleanup deletes or orphans the applied objects of configSync according to
+// spec.deletionPolicy, unless the skip-cleanup annotation is set.
+func (r *ConfigSyncReconciler) cleanup(ctx context.Context, configSync *configsv1alpha1.ConfigSync) error {
+	log := logf.FromContext(ctx)
+	inventory := configSync.Status.Inventory
+
+	if configSync.Annotations[configsv1alpha1.SkipCleanupAnnotation] == "true" {
+		log.Info("Skipping cleanup of applied objects", "count", len(inventory))
+		r.event(configSync, corev1.EventTypeNormal, "CleanupSkipped",
+			fmt.Sprintf("Left %d applied object(s) untouched", len(inventory)))
+		return nil
+	}
+
+	applyClient, err := r.applyClient(ctx, configSync)
+	if err != nil {
+		return &clientError{err: err}
+	}
+	if configSync.Spec.DeletionPolicy == configsv1alpha1.DeletionPolicyDelete {
+		log.Info("Deleting applied objects", "count", len(inventory))
+		_, err = apply.Prune(ctx, applyClient, inventory, nil)
+		return err
+	}
+	log.Info("Orphaning applied objects", "count", len(inventory))
+	return apply.Orphan(ctx, applyClient, inventory)
+}
+
+// event records an event about configSync when the reconciler has a Recorder.
+func (r *ConfigSyncReconciler) event(configSync *configsv1alpha1.ConfigSync, eventType, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(configSync, eventType, reason, message)
+	}
+}
+
+// releaseCache removes the cached clone used by configSync unless another
+// ConfigSync still syncs from the same repository.
+func (r *ConfigSyncReconciler) releaseCache(ctx context.Context, configSync *configsv1alpha1.ConfigSync) error {
+	if configSync.Spec.Source.Git == nil {
+		return nil
+	}
+	repoURL := configSync.Spec.Source.Git.RepoURL
+
+	var all configsv1alpha1.ConfigSyncList
+	if err := r.List(ctx, &all); err != nil {
+		return err
+	}
+	for _, other := range all.Items {
+		if other.UID == configSync.UID || other.Spec.Source.Git == nil {
+			continue
+		}
+		if other.Spec.Source.Git.RepoURL == repoURL {
+			return nil
+		}
+	}
+
+	return r.SourceOptions.RemoveCache(repoURL)
+}
+
 // SetupWithManager
 // --------------------------------------------------------------
 func (r *ConfigSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&configsv1alpha1.ConfigSync{}).
-		Named("configsync").
-		Complete(r)
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.configSyncsForSource("ConfigMap"))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.configSyncsForSource("Secret"))).
+		Watches(&configsv1alpha1.ConfigSync{}, handler.EnqueueRequestsFromMapFunc(r.configSyncsDependingOn),
+			builder.WithPredicates(dependencyReadinessChanged))
+	if r.Triggers != nil {
+		b = b.WatchesRawSource(ctrlsource.Channel(r.Triggers, &handler.EnqueueRequestForObject{}))
+	}
+	return b.Named("configsync").Complete(r)
+}
+
+// configSyncsForSource maps a ConfigMap or Secret, as selected by kind, to
+// the ConfigSyncs in its namespace that read it through
+// spec.source.configMapRef or spec.source.secretRef, so that changes are
+// synced without waiting for the refresh interval.
+func (r *ConfigSyncReconciler) configSyncsForSource(kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var list configsv1alpha1.ConfigSyncList
+		if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace())); err != nil {
+			logf.FromContext(ctx).Error(err, "failed to list ConfigSyncs", "kind", kind, "name", obj.GetName())
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, cs := range list.Items {
+			ref := cs.Spec.Source.ConfigMapRef
+			if kind == "Secret" {
+				ref = cs.Spec.Source.SecretRef
+			}
+			if ref != nil && ref.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&cs)})
+			}
+		}
+		return requests
+	}
 }
 
 // Condition helper
 // --------------------------------------------------------------
+
+// markDegraded records a failed reconcile: the ConfigSync is Degraded and no
+// longer Ready, both for reason.
+func markDegraded(status *configsv1alpha1.ConfigSyncStatus, reason, message string) {
+	setCondition(status, "Degraded", metav1.ConditionTrue, reason, message)
+	setCondition(status, "Ready", metav1.ConditionFalse, reason, message)
+}
+
 func setCondition(status *configsv1alpha1.ConfigSyncStatus, conditionType string, statusValue metav1.ConditionStatus, reason, message string) {
 	now := metav1.Now()
 	for i, c := range status.Conditions {
@@ -17,19 +17,196 @@ limitations under the License.
 package controller
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-containerregistry/pkg/registry"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/record"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry/remote"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+	apply "github.com/joe-bresee/config-synchronizer-operator/internal/apply"
 )
 
+// newGitRepo creates a local Git repository holding files and returns its path.
+func newGitRepo(files map[string]string) string {
+	dir := GinkgoT().TempDir()
+	_, err := git.PlainInit(dir, false)
+	Expect(err).NotTo(HaveOccurred())
+	commitFiles(dir, files)
+	return dir
+}
+
+// commitFiles writes files into the repository at dir and commits them.
+func commitFiles(dir string, files map[string]string) {
+	repo, err := git.PlainOpen(dir)
+	Expect(err).NotTo(HaveOccurred())
+	w, err := repo.Worktree()
+	Expect(err).NotTo(HaveOccurred())
+
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		Expect(os.MkdirAll(filepath.Dir(p), 0o755)).To(Succeed())
+		Expect(os.WriteFile(p, []byte(content), 0o644)).To(Succeed())
+		_, err := w.Add(name)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	_, err = w.Commit("update", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// configMapManifest returns a ConfigMap manifest with a single data key.
+func configMapManifest(name, key, value string) string {
+	return "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\ndata:\n  " + key + ": \"" + value + "\"\n"
+}
+
+// newConfigSync returns a ConfigSync in the default namespace that applies the
+// manifests under path in repo.
+func newConfigSync(name, repo, path string) *configsv1alpha1.ConfigSync {
+	return &configsv1alpha1.ConfigSync{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: configsv1alpha1.ConfigSyncSpec{
+			Source: configsv1alpha1.SourceSpec{Git: &configsv1alpha1.GitSource{
+				RepoURL:    repo,
+				Path:       path,
+				AuthMethod: "none",
+			}},
+			Targets: []configsv1alpha1.TargetRef{{Namespace: "default", Name: name, Type: "Deployment"}},
+		},
+	}
+}
+
+// reconcileConfigSync runs a single reconcile of the named ConfigSync.
+func reconcileConfigSync(ctx context.Context, name string) (reconcile.Result, error) {
+	r := &ConfigSyncReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Config: cfg}
+	return r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}})
+}
+
+// getConfigSync fetches the named ConfigSync from the default namespace.
+func getConfigSync(ctx context.Context, name string) *configsv1alpha1.ConfigSync {
+	cs := &configsv1alpha1.ConfigSync{}
+	Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, cs)).To(Succeed())
+	return cs
+}
+
+// getConfigMap fetches the named ConfigMap from the default namespace.
+func getConfigMap(ctx context.Context, name string) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, cm)
+	return cm, err
+}
+
+// deleteConfigSync deletes the named ConfigSync and reconciles it until its
+// finalizer has been released.
+func deleteConfigSync(ctx context.Context, name string) {
+	cs := &configsv1alpha1.ConfigSync{}
+	err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, cs)
+	if errors.IsNotFound(err) {
+		return
+	}
+	Expect(err).NotTo(HaveOccurred())
+	Expect(k8sClient.Delete(ctx, cs)).To(Succeed())
+	_, err = reconcileConfigSync(ctx, name)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, cs)
+	Expect(errors.IsNotFound(err)).To(BeTrue(), "expected ConfigSync %s to be gone, got %v", name, err)
+}
+
+// kubeConfigFor returns a kubeconfig with embedded credentials for restConfig.
+func kubeConfigFor(restConfig *rest.Config) []byte {
+	kubeConfig := clientcmdapi.NewConfig()
+	kubeConfig.Clusters["remote"] = &clientcmdapi.Cluster{
+		Server:                   restConfig.Host,
+		CertificateAuthorityData: restConfig.CAData,
+	}
+	kubeConfig.AuthInfos["remote"] = &clientcmdapi.AuthInfo{
+		ClientCertificateData: restConfig.CertData,
+		ClientKeyData:         restConfig.KeyData,
+		Token:                 restConfig.BearerToken,
+	}
+	kubeConfig.Contexts["remote"] = &clientcmdapi.Context{Cluster: "remote", AuthInfo: "remote"}
+	kubeConfig.CurrentContext = "remote"
+
+	data, err := clientcmd.Write(*kubeConfig)
+	Expect(err).NotTo(HaveOccurred())
+	return data
+}
+
+// tarGzip packages files as a gzipped tarball.
+func tarGzip(files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))})).To(Succeed())
+		_, err := tw.Write([]byte(content))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(tw.Close()).To(Succeed())
+	Expect(gz.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+// pushBundle packages files as a gzipped tarball and pushes it to the
+// configs repository of the registry at host under tag, returning the
+// manifest digest.
+func pushBundle(host, tag string, files map[string]string) string {
+	data := tarGzip(files)
+
+	ctx := context.Background()
+	repo, err := remote.NewRepository(host + "/configs")
+	Expect(err).NotTo(HaveOccurred())
+	repo.PlainHTTP = true
+	layer := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(data),
+		Size:      int64(len(data)),
+	}
+	Expect(repo.Push(ctx, layer, bytes.NewReader(data))).To(Succeed())
+	manifest, err := oras.PackManifest(ctx, repo, oras.PackManifestVersion1_1, "application/vnd.example.config.v1",
+		oras.PackManifestOptions{Layers: []ocispec.Descriptor{layer}})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(repo.Tag(ctx, manifest, tag)).To(Succeed())
+	return manifest.Digest.String()
+}
+
 var _ = Describe("ConfigSync Controller", func() {
 	Context("When reconciling a resource", func() {
 		const resourceName = "test-resource"
@@ -46,39 +223,1175 @@ var _ = Describe("ConfigSync Controller", func() {
 			By("creating the custom resource for the Kind ConfigSync")
 			err := k8sClient.Get(ctx, typeNamespacedName, configsync)
 			if err != nil && errors.IsNotFound(err) {
-				resource := &configsv1alpha1.ConfigSync{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      resourceName,
-						Namespace: "default",
-					},
-					// TODO(user): Specify other spec details if needed.
-				}
+				repo := newGitRepo(map[string]string{
+					"manifests/cm.yaml": configMapManifest(resourceName, "key", "value"),
+				})
+				resource := newConfigSync(resourceName, repo, "manifests")
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 			}
 		})
 
 		AfterEach(func() {
-			// TODO(user): Cleanup logic after each test, like removing the resource instance.
-			resource := &configsv1alpha1.ConfigSync{}
-			err := k8sClient.Get(ctx, typeNamespacedName, resource)
-			Expect(err).NotTo(HaveOccurred())
-
 			By("Cleanup the specific resource instance ConfigSync")
-			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			deleteConfigSync(ctx, resourceName)
 		})
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
-			controllerReconciler := &ConfigSyncReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+			_, err := reconcileConfigSync(ctx, resourceName)
+			Expect(err).NotTo(HaveOccurred())
+
+			cs := getConfigSync(ctx, resourceName)
+			Expect(cs.Status.SourceRevision).NotTo(BeEmpty())
+			Expect(cs.Status.Inventory).To(ConsistOf(configsv1alpha1.ResourceRef{
+				Kind: "ConfigMap", Namespace: "default", Name: resourceName,
+			}))
+			cm, err := getConfigMap(ctx, resourceName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("key", "value"))
+		})
+	})
+
+	Context("When render inputs change at the same revision", func() {
+		const name = "templated"
+
+		ctx := context.Background()
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+			_ = k8sClient.Delete(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "template-values", Namespace: "default"}})
+		})
+
+		It("re-applies when inline or referenced values change", func() {
+			repo := newGitRepo(map[string]string{
+				"manifests/cm.yaml": configMapManifest(name, "greeting", "{{ .Values.greeting }} {{ .Values.name }}"),
+			})
+			values := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "template-values", Namespace: "default"},
+				Data:       map[string]string{"name": "world"},
 			}
+			Expect(k8sClient.Create(ctx, values)).To(Succeed())
+
+			cs := newConfigSync(name, repo, "manifests")
+			// Report keeps drift correction from masking a missing apply
+			cs.Spec.DriftPolicy = configsv1alpha1.DriftPolicyReport
+			cs.Spec.Render = &configsv1alpha1.RenderSpec{Template: &configsv1alpha1.TemplateRender{
+				Values:     &apiextensionsv1.JSON{Raw: []byte(`{"greeting":"hello"}`)},
+				ValuesFrom: []configsv1alpha1.ValuesReference{{Kind: "ConfigMap", Name: "template-values"}},
+			}}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			cm, err := getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("greeting", "hello world"))
+
+			By("changing the inline values")
+			cs = getConfigSync(ctx, name)
+			cs.Spec.Render.Template.Values = &apiextensionsv1.JSON{Raw: []byte(`{"greeting":"hi"}`)}
+			Expect(k8sClient.Update(ctx, cs)).To(Succeed())
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			cm, err = getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("greeting", "hi world"))
+
+			By("changing the referenced values")
+			values.Data["name"] = "there"
+			Expect(k8sClient.Update(ctx, values)).To(Succeed())
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			cm, err = getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("greeting", "hi there"))
+
+			cs = getConfigSync(ctx, name)
+			Expect(meta.IsStatusConditionTrue(cs.Status.Conditions, "Drifted")).To(BeFalse())
+			Expect(cs.Status.ObservedGeneration).To(Equal(cs.Generation))
+		})
+	})
+
+	Context("When rendering a Helm chart from the source", func() {
+		const name = "helm-chart"
+
+		ctx := context.Background()
 
-			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
-				NamespacedName: typeNamespacedName,
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+		})
+
+		It("applies the chart rendered with the merged values", func() {
+			repo := newGitRepo(map[string]string{
+				"charts/demo/Chart.yaml":  "apiVersion: v2\nname: demo\nversion: 0.1.0\n",
+				"charts/demo/values.yaml": "greeting: hello\nname: world\n",
+				"charts/demo/templates/cm.yaml": configMapManifest("{{ .Release.Name }}", "greeting",
+					"{{ .Values.greeting }} {{ .Values.name }}"),
+				"values/prod.yaml": "name: prod\n",
 			})
+			cs := newConfigSync(name, repo, "charts/demo")
+			cs.Spec.Render = &configsv1alpha1.RenderSpec{Helm: &configsv1alpha1.HelmRender{
+				Values:      &apiextensionsv1.JSON{Raw: []byte(`{"greeting":"hi"}`)},
+				ValuesFiles: []string{"values/prod.yaml"},
+			}}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			cm, err := getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("greeting", "hi prod"))
+
+			cs = getConfigSync(ctx, name)
+			Expect(cs.Status.RenderDigest).NotTo(BeEmpty())
+			Expect(cs.Status.Inventory).To(ConsistOf(configsv1alpha1.ResourceRef{
+				Kind: "ConfigMap", Namespace: "default", Name: name,
+			}))
+		})
+	})
+
+	Context("When applied objects drift at the same revision", func() {
+		const name = "drifting"
+
+		ctx := context.Background()
+		ref := configsv1alpha1.ResourceRef{Kind: "ConfigMap", Namespace: "default", Name: name}
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+			_ = k8sClient.Delete(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})
+		})
+
+		// editConfigMap changes the mode key as another field manager would.
+		editConfigMap := func() {
+			cm, err := getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			cm.Data["mode"] = "permissive"
+			Expect(k8sClient.Update(ctx, cm, client.FieldOwner("kubectl-edit"))).To(Succeed())
+		}
+
+		createAndSync := func(policy string) {
+			repo := newGitRepo(map[string]string{"manifests/cm.yaml": configMapManifest(name, "mode", "strict")})
+			cs := newConfigSync(name, repo, "manifests")
+			cs.Spec.DriftPolicy = policy
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("re-applies drifted objects with the Correct policy", func() {
+			createAndSync(configsv1alpha1.DriftPolicyCorrect)
+
+			By("editing an applied object")
+			editConfigMap()
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+
+			cm, err := getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("mode", "strict"))
+			cs := getConfigSync(ctx, name)
+			Expect(cs.Status.Drifted).To(BeEmpty())
+			cond := meta.FindStatusCondition(cs.Status.Conditions, "Drifted")
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal("DriftCorrected"))
+
+			By("deleting an applied object that is missing from the inventory")
+			cs.Status.Inventory = nil
+			Expect(k8sClient.Status().Update(ctx, cs)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, cm)).To(Succeed())
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(getConfigSync(ctx, name).Status.Inventory).To(ConsistOf(ref))
+		})
+
+		It("only reports drifted objects with the Report policy", func() {
+			createAndSync(configsv1alpha1.DriftPolicyReport)
+
+			By("adding fields owned by another manager")
+			other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: "default", Labels: map[string]string{"team": "platform"},
+			}}
+			other.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+			Expect(k8sClient.Patch(ctx, other, client.Apply, client.FieldOwner("kubectl"))).To(Succeed())
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(meta.IsStatusConditionTrue(getConfigSync(ctx, name).Status.Conditions, "Drifted")).To(BeFalse())
+
+			By("editing a field owned by the operator")
+			editConfigMap()
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+
+			cm, err := getConfigMap(ctx, name)
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+			Expect(cm.Data).To(HaveKeyWithValue("mode", "permissive"))
+			cs := getConfigSync(ctx, name)
+			Expect(cs.Status.Drifted).To(ConsistOf(ref))
+			Expect(meta.IsStatusConditionTrue(cs.Status.Conditions, "Drifted")).To(BeTrue())
+		})
+	})
+
+	Context("When signature verification is required", func() {
+		const name = "verified"
+
+		ctx := context.Background()
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+			_ = k8sClient.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "trusted-keys", Namespace: "default"}})
+		})
+
+		It("refuses to apply an unsigned revision", func() {
+			repo := newGitRepo(map[string]string{
+				"manifests/cm.yaml": configMapManifest(name, "key", "value"),
+			})
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "trusted-keys", Namespace: "default"},
+				StringData: map[string]string{
+					"allowed_signers": "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl\n",
+				},
+			})).To(Succeed())
+			cs := newConfigSync(name, repo, "manifests")
+			cs.Spec.Source.Git.Verify = &configsv1alpha1.GitVerification{
+				SecretRef: configsv1alpha1.LocalObjectReference{Name: "trusted-keys"},
+			}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).To(HaveOccurred())
+
+			cs = getConfigSync(ctx, name)
+			degraded := meta.FindStatusCondition(cs.Status.Conditions, "Degraded")
+			Expect(degraded).NotTo(BeNil())
+			Expect(degraded.Reason).To(Equal("VerificationFailed"))
+			Expect(cs.Status.SourceRevision).To(BeEmpty())
+			_, err = getConfigMap(ctx, name)
+			Expect(errors.IsNotFound(err)).To(BeTrue(), "expected nothing applied, got %v", err)
+		})
+	})
+
+	Context("When the source is an OCI artifact", func() {
+		const name = "oci-bundle"
+
+		ctx := context.Background()
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+		})
+
+		It("applies the pulled bundle and records its digest", func() {
+			srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+			DeferCleanup(srv.Close)
+			host := strings.TrimPrefix(srv.URL, "http://")
+			pushBundle(host, "v1.0.0", map[string]string{"manifests/cm.yaml": configMapManifest(name, "key", "v1")})
+			v11 := pushBundle(host, "v1.1.0", map[string]string{"manifests/cm.yaml": configMapManifest(name, "key", "v1.1")})
+
+			cs := newConfigSync(name, "", "")
+			cs.Spec.Source = configsv1alpha1.SourceSpec{OCI: &configsv1alpha1.OCISource{
+				URL:      "oci://" + host + "/configs",
+				Ref:      &configsv1alpha1.OCIRef{Semver: "^1.0.0"},
+				Path:     "manifests",
+				Insecure: true,
+			}}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+
+			cm, err := getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("key", "v1.1"))
+			cs = getConfigSync(ctx, name)
+			Expect(cs.Status.SourceRevision).To(Equal(v11))
+			Expect(cs.Status.SourceTag).To(Equal("v1.1.0"))
+		})
+
+		It("rejects a ConfigSync with more than one source", func() {
+			cs := newConfigSync(name, newGitRepo(map[string]string{"manifests/cm.yaml": ""}), "manifests")
+			cs.Spec.Source.OCI = &configsv1alpha1.OCISource{URL: "oci://registry.example.com/configs"}
+			err := k8sClient.Create(ctx, cs)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("exactly one source must be set"))
+		})
+	})
+
+	Context("When the source is an HTTP archive", func() {
+		const name = "http-archive"
+
+		ctx := context.Background()
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+		})
+
+		It("applies the archive and only re-applies when its content changes", func() {
+			archive := tarGzip(map[string]string{"manifests/cm.yaml": configMapManifest(name, "key", "v1")})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write(archive)
+			}))
+			DeferCleanup(srv.Close)
+
+			cs := newConfigSync(name, "", "")
+			cs.Spec.Source = configsv1alpha1.SourceSpec{HTTP: &configsv1alpha1.HTTPSource{
+				URL:  srv.URL + "/configs.tar.gz",
+				Path: "manifests",
+			}}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			cm, err := getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("key", "v1"))
+			first := getConfigSync(ctx, name).Status.SourceRevision
+			Expect(first).To(HavePrefix("sha256:"))
+
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(getConfigSync(ctx, name).Status.SourceRevision).To(Equal(first))
+
+			archive = tarGzip(map[string]string{"manifests/cm.yaml": configMapManifest(name, "key", "v2")})
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			cm, err = getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("key", "v2"))
+			Expect(getConfigSync(ctx, name).Status.SourceRevision).NotTo(Equal(first))
+		})
+	})
+
+	Context("When the source is a ConfigMap", func() {
+		const name = "shared-ca"
+
+		ctx := context.Background()
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: name + "-source", Namespace: "default"},
+			}))).To(Succeed())
+		})
+
+		It("fans the ConfigMap out to targets in other namespaces and follows its changes", func() {
+			tenant := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "tenant-a"}}
+			Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, tenant))).To(Succeed())
+			sourceCM := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: name + "-source", Namespace: "default"},
+				Data:       map[string]string{"ca.crt": "v1", "notes.txt": "ignored"},
+			}
+			Expect(k8sClient.Create(ctx, sourceCM)).To(Succeed())
+
+			cs := newConfigSync(name, "", "")
+			cs.Spec.Source = configsv1alpha1.SourceSpec{ConfigMapRef: &configsv1alpha1.ObjectSource{
+				Name:    name + "-source",
+				Include: []string{"*.crt"},
+			}}
+			cs.Spec.Targets = []configsv1alpha1.TargetRef{{Namespace: "tenant-a", Name: "ca-bundle", Type: "ConfigMap"}}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			target := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "tenant-a", Name: "ca-bundle"}, target)).To(Succeed())
+			Expect(target.Data).To(Equal(map[string]string{"ca.crt": "v1"}))
+
+			r := &ConfigSyncReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			Expect(r.configSyncsForSource("ConfigMap")(ctx, sourceCM)).To(ConsistOf(
+				reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}}))
+			Expect(r.configSyncsForSource("Secret")(ctx, sourceCM)).To(BeEmpty())
+
+			sourceCM.Data["ca.crt"] = "v2"
+			Expect(k8sClient.Update(ctx, sourceCM)).To(Succeed())
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "tenant-a", Name: "ca-bundle"}, target)).To(Succeed())
+			Expect(target.Data).To(Equal(map[string]string{"ca.crt": "v2"}))
+		})
+	})
+
+	Context("When manifests depend on each other", func() {
+		const name = "ordered"
+
+		ctx := context.Background()
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+		})
+
+		It("applies namespaces and CRDs before the objects that need them", func() {
+			repo := newGitRepo(map[string]string{
+				"manifests/a-widget.yaml": `apiVersion: example.io/v1
+kind: Widget
+metadata:
+  name: sprocket
+  namespace: widgets
+spec:
+  size: 3
+`,
+				"manifests/b-config.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: widget-config
+  namespace: widgets
+  annotations:
+    configs.example.io/depends-on: Widget/sprocket
+`,
+				"manifests/y-namespace.yaml": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: widgets
+`,
+				"manifests/z-crd.yaml": `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.io
+spec:
+  group: example.io
+  names: {kind: Widget, plural: widgets, singular: widget, listKind: WidgetList}
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        x-kubernetes-preserve-unknown-fields: true
+`,
+			})
+			cs := newConfigSync(name, repo, "manifests")
+			cs.Spec.Targets[0].Namespace = ""
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+
+			widget := &unstructured.Unstructured{}
+			widget.SetAPIVersion("example.io/v1")
+			widget.SetKind("Widget")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "widgets", Name: "sprocket"}, widget)).To(Succeed())
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "widgets", Name: "widget-config"}, &corev1.ConfigMap{})).To(Succeed())
+			Expect(getConfigSync(ctx, name).Status.Inventory).To(HaveLen(4))
+		})
+	})
+
+	Context("When applied workloads roll out", func() {
+		const name = "health"
+
+		ctx := context.Background()
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+			_ = k8sClient.Delete(ctx, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}})
+		})
+
+		It("reports Ready once the Deployment is available", func() {
+			repo := newGitRepo(map[string]string{
+				"manifests/deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  selector:
+    matchLabels: {app: web}
+  template:
+    metadata:
+      labels: {app: web}
+    spec:
+      containers:
+      - name: web
+        image: nginx
+`,
+			})
+			cs := newConfigSync(name, repo, "manifests")
+			cs.Spec.Wait = &configsv1alpha1.WaitSpec{Timeout: metav1.Duration{Duration: time.Minute}}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			result, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(healthPollInterval))
+
+			cs = getConfigSync(ctx, name)
+			Expect(cs.Status.Health).To(ConsistOf(HaveField("Status", configsv1alpha1.HealthInProgress)))
+			ready := meta.FindStatusCondition(cs.Status.Conditions, "Ready")
+			Expect(ready).NotTo(BeNil())
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal("Progressing"))
+			Expect(meta.IsStatusConditionTrue(cs.Status.Conditions, "Progressing")).To(BeTrue())
+
+			// There is no controller manager in envtest; roll the Deployment out by hand
+			deploy := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web"}, deploy)).To(Succeed())
+			deploy.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: deploy.Generation,
+				Replicas:           1,
+				UpdatedReplicas:    1,
+				ReadyReplicas:      1,
+				AvailableReplicas:  1,
+			}
+			Expect(k8sClient.Status().Update(ctx, deploy)).To(Succeed())
+
+			result, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(30 * time.Second))
+
+			cs = getConfigSync(ctx, name)
+			Expect(cs.Status.Health).To(ConsistOf(HaveField("Status", configsv1alpha1.HealthCurrent)))
+			Expect(meta.IsStatusConditionTrue(cs.Status.Conditions, "Ready")).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(cs.Status.Conditions, "Progressing")).To(BeTrue())
+		})
+
+		It("stops waiting once the timeout expires", func() {
+			applied := time.Now()
+			cs := &configsv1alpha1.ConfigSync{
+				Spec: configsv1alpha1.ConfigSyncSpec{Wait: &configsv1alpha1.WaitSpec{Timeout: metav1.Duration{Duration: time.Minute}}},
+				Status: configsv1alpha1.ConfigSyncStatus{
+					LastAppliedTime: &metav1.Time{Time: applied},
+					Health: []configsv1alpha1.ObjectHealth{{
+						ResourceRef: configsv1alpha1.ResourceRef{Group: "apps", Kind: "Deployment", Namespace: "default", Name: "web"},
+						Status:      configsv1alpha1.HealthInProgress,
+					}},
+				},
+			}
+			Expect(setHealthConditions(cs, applied.Add(30*time.Second))).To(BeTrue())
+
+			Expect(setHealthConditions(cs, applied.Add(2*time.Minute))).To(BeFalse())
+			ready := meta.FindStatusCondition(cs.Status.Conditions, "Ready")
+			Expect(ready.Reason).To(Equal("HealthCheckTimeout"))
+			Expect(ready.Message).To(ContainSubstring("Deployment default/web"))
+
+			cs.Status.Health[0].Status = configsv1alpha1.HealthFailed
+			Expect(setHealthConditions(cs, applied.Add(30*time.Second))).To(BeFalse())
+			Expect(meta.FindStatusCondition(cs.Status.Conditions, "Ready").Reason).To(Equal("HealthCheckFailed"))
+		})
+
+		It("counts a completed Job removed by its TTL as current", func() {
+			cs := &configsv1alpha1.ConfigSync{
+				Status: configsv1alpha1.ConfigSyncStatus{
+					SourceRevision: "abc",
+					History: []configsv1alpha1.SyncRecord{{
+						Revision: "abc",
+						Result:   configsv1alpha1.SyncResultHealthy,
+					}},
+					Health: []configsv1alpha1.ObjectHealth{{
+						ResourceRef: configsv1alpha1.ResourceRef{Group: "batch", Kind: "Job", Namespace: "default", Name: "migrate"},
+						Status:      configsv1alpha1.HealthNotFound,
+					}},
+				},
+			}
+			Expect(setHealthConditions(cs, time.Now())).To(BeFalse())
+			Expect(cs.Status.Health).To(ConsistOf(HaveField("Status", configsv1alpha1.HealthCurrent)))
+			Expect(meta.IsStatusConditionTrue(cs.Status.Conditions, "Ready")).To(BeTrue())
+
+			// Until the revision has become healthy, a missing Job has not run yet
+			cs.Status.History[0].Result = configsv1alpha1.SyncResultApplied
+			cs.Status.Health[0].Status = configsv1alpha1.HealthNotFound
+			Expect(setHealthConditions(cs, time.Now())).To(BeFalse())
+			ready := meta.FindStatusCondition(cs.Status.Conditions, "Ready")
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal("Progressing"))
+		})
+	})
+
+	Context("When a ConfigSync depends on another", func() {
+		const base, app = "base", "app"
+
+		ctx := context.Background()
+
+		AfterEach(func() {
+			for _, name := range []string{app, base} {
+				cs := &configsv1alpha1.ConfigSync{}
+				if err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, cs); err == nil {
+					cs.Spec.DependsOn = nil
+					Expect(k8sClient.Update(ctx, cs)).To(Succeed())
+				}
+				deleteConfigSync(ctx, name)
+			}
+		})
+
+		It("waits until the dependency is ready", func() {
+			Expect(k8sClient.Create(ctx, newConfigSync(base, newGitRepo(map[string]string{
+				"manifests/cm.yaml": configMapManifest("base-config", "tier", "base"),
+			}), "manifests"))).To(Succeed())
+			cs := newConfigSync(app, newGitRepo(map[string]string{
+				"manifests/cm.yaml": configMapManifest("app-config", "tier", "app"),
+			}), "manifests")
+			cs.Spec.DependsOn = []configsv1alpha1.DependencyReference{{Name: base}}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			result, err := reconcileConfigSync(ctx, app)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(dependencyRequeueInterval))
+			ready := meta.FindStatusCondition(getConfigSync(ctx, app).Status.Conditions, "Ready")
+			Expect(ready).NotTo(BeNil())
+			Expect(ready.Reason).To(Equal("DependencyNotReady"))
+			_, err = getConfigMap(ctx, "app-config")
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			r := &ConfigSyncReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			Expect(r.configSyncsDependingOn(ctx, getConfigSync(ctx, base))).To(ConsistOf(
+				reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: app}}))
+
+			_, err = reconcileConfigSync(ctx, base)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(meta.IsStatusConditionTrue(getConfigSync(ctx, base).Status.Conditions, "Ready")).To(BeTrue())
+
+			_, err = reconcileConfigSync(ctx, app)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(meta.IsStatusConditionTrue(getConfigSync(ctx, app).Status.Conditions, "Ready")).To(BeTrue())
+			_, err = getConfigMap(ctx, "app-config")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("keeps a requested sync of a suspended ConfigSync until its dependencies are ready", func() {
+			Expect(k8sClient.Create(ctx, newConfigSync(base, newGitRepo(map[string]string{
+				"manifests/cm.yaml": configMapManifest("base-settings", "tier", "base"),
+			}), "manifests"))).To(Succeed())
+			cs := newConfigSync(app, newGitRepo(map[string]string{
+				"manifests/cm.yaml": configMapManifest("app-settings", "tier", "app"),
+			}), "manifests")
+			cs.Spec.Suspend = true
+			cs.Spec.DependsOn = []configsv1alpha1.DependencyReference{{Name: base}}
+			cs.Annotations = map[string]string{configsv1alpha1.ReconcileRequestAnnotation: "2026-10-17T10:00:00Z"}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			result, err := reconcileConfigSync(ctx, app)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(dependencyRequeueInterval))
+			Expect(getConfigSync(ctx, app).Status.LastHandledReconcileAt).To(BeEmpty())
+			_, err = getConfigMap(ctx, "app-settings")
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			_, err = reconcileConfigSync(ctx, base)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = reconcileConfigSync(ctx, app)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(getConfigSync(ctx, app).Status.LastHandledReconcileAt).To(Equal("2026-10-17T10:00:00Z"))
+			_, err = getConfigMap(ctx, "app-settings")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reports dependency cycles", func() {
+			repo := newGitRepo(map[string]string{"manifests/cm.yaml": configMapManifest("cyclic", "k", "v")})
+			for name, dependency := range map[string]string{base: app, app: base} {
+				cs := newConfigSync(name, repo, "manifests")
+				cs.Spec.DependsOn = []configsv1alpha1.DependencyReference{{Namespace: "default", Name: dependency}}
+				Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+			}
+
+			_, err := reconcileConfigSync(ctx, app)
+			Expect(err).NotTo(HaveOccurred())
+			degraded := meta.FindStatusCondition(getConfigSync(ctx, app).Status.Conditions, "Degraded")
+			Expect(degraded).NotTo(BeNil())
+			Expect(degraded.Reason).To(Equal("DependencyCycle"))
+			Expect(degraded.Message).To(ContainSubstring("default/app -> default/base -> default/app"))
+		})
+	})
+
+	Context("When a ConfigSync is suspended", func() {
+		const name = "suspended"
+
+		ctx := context.Background()
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+		})
+
+		It("only syncs on request until it is resumed", func() {
+			repo := newGitRepo(map[string]string{"manifests/cm.yaml": configMapManifest("frozen", "version", "v1")})
+			cs := newConfigSync(name, repo, "manifests")
+			cs.Spec.Suspend = true
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			result, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{}))
+			cs = getConfigSync(ctx, name)
+			Expect(meta.IsStatusConditionTrue(cs.Status.Conditions, "Suspended")).To(BeTrue())
+			Expect(cs.Status.SourceRevision).To(BeEmpty())
+			_, err = getConfigMap(ctx, "frozen")
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			// A manual sync is run once per annotation value
+			cs.Annotations = map[string]string{configsv1alpha1.ReconcileRequestAnnotation: "2026-10-17T10:00:00Z"}
+			Expect(k8sClient.Update(ctx, cs)).To(Succeed())
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			cm, err := getConfigMap(ctx, "frozen")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data["version"]).To(Equal("v1"))
+			cs = getConfigSync(ctx, name)
+			Expect(cs.Status.LastHandledReconcileAt).To(Equal("2026-10-17T10:00:00Z"))
+			Expect(meta.IsStatusConditionTrue(cs.Status.Conditions, "Suspended")).To(BeTrue())
+
+			commitFiles(repo, map[string]string{"manifests/cm.yaml": configMapManifest("frozen", "version", "v2")})
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			cm, err = getConfigMap(ctx, "frozen")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data["version"]).To(Equal("v1"))
+
+			cs = getConfigSync(ctx, name)
+			cs.Spec.Suspend = false
+			Expect(k8sClient.Update(ctx, cs)).To(Succeed())
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			cm, err = getConfigMap(ctx, "frozen")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data["version"]).To(Equal("v2"))
+			Expect(meta.FindStatusCondition(getConfigSync(ctx, name).Status.Conditions, "Suspended")).To(BeNil())
+		})
+	})
+
+	Context("When rolling back to a prior revision", func() {
+		const name = "rollback"
+
+		ctx := context.Background()
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+			_ = k8sClient.Delete(ctx, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"}})
+		})
+
+		// syncVersion commits version of the release ConfigMap and reconciles.
+		syncVersion := func(repo, version string) {
+			commitFiles(repo, map[string]string{"manifests/cm.yaml": configMapManifest("release", "version", version)})
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		expectVersion := func(version string) {
+			cm, err := getConfigMap(ctx, "release")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data["version"]).To(Equal(version))
+		}
+
+		It("re-applies the chosen revision until the rollback is cleared", func() {
+			repo := newGitRepo(map[string]string{"manifests/cm.yaml": configMapManifest("release", "version", "v1")})
+			Expect(k8sClient.Create(ctx, newConfigSync(name, repo, "manifests"))).To(Succeed())
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			syncVersion(repo, "v2")
+
+			cs := getConfigSync(ctx, name)
+			Expect(cs.Status.History).To(HaveLen(2))
+			v1 := cs.Status.History[1]
+			Expect(v1.Result).To(Equal(configsv1alpha1.SyncResultHealthy))
+			Expect(v1.Message).To(Equal("update"))
+			Expect(v1.Author).To(Equal("test <test@example.com>"))
+			Expect(v1.Objects).To(Equal(1))
+
+			cs.Spec.Rollback = &configsv1alpha1.RollbackSpec{Revision: v1.Revision}
+			Expect(k8sClient.Update(ctx, cs)).To(Succeed())
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			expectVersion("v1")
+			cs = getConfigSync(ctx, name)
+			Expect(cs.Status.History[0].Revision).To(Equal(v1.Revision))
+			Expect(cs.Status.History[0].Rollback).To(BeTrue())
+			rolledBack := meta.FindStatusCondition(cs.Status.Conditions, "RolledBack")
+			Expect(rolledBack).NotTo(BeNil())
+			Expect(rolledBack.Reason).To(Equal("ManualRollback"))
+
+			// Forward sync is paused while rolled back
+			syncVersion(repo, "v3")
+			expectVersion("v1")
+
+			cs = getConfigSync(ctx, name)
+			cs.Spec.Rollback = nil
+			Expect(k8sClient.Update(ctx, cs)).To(Succeed())
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			expectVersion("v3")
+			Expect(meta.FindStatusCondition(getConfigSync(ctx, name).Status.Conditions, "RolledBack")).To(BeNil())
+		})
+
+		It("rolls back automatically when a revision fails its health checks", func() {
+			repo := newGitRepo(map[string]string{"manifests/cm.yaml": configMapManifest("release", "version", "v1")})
+			cs := newConfigSync(name, repo, "manifests")
+			cs.Spec.Wait = &configsv1alpha1.WaitSpec{Timeout: metav1.Duration{Duration: time.Minute}, RollbackOnFailure: true}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			good := getConfigSync(ctx, name).Status.SourceRevision
+
+			deployment := func(image string) string {
+				return `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  selector:
+    matchLabels: {app: api}
+  template:
+    metadata:
+      labels: {app: api}
+    spec:
+      containers:
+      - name: api
+        image: ` + image + "\n"
+			}
+			commitFiles(repo, map[string]string{"manifests/deploy.yaml": deployment("api:broken")})
+			syncVersion(repo, "v2")
+			bad := getConfigSync(ctx, name).Status.SourceRevision
+
+			deploy := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "api"}, deploy)).To(Succeed())
+			deploy.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: deploy.Generation,
+				Conditions: []appsv1.DeploymentCondition{{
+					Type:   appsv1.DeploymentProgressing,
+					Status: corev1.ConditionFalse,
+					Reason: "ProgressDeadlineExceeded",
+				}},
+			}
+			Expect(k8sClient.Status().Update(ctx, deploy)).To(Succeed())
+
+			result, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(healthPollInterval))
+			cs = getConfigSync(ctx, name)
+			Expect(cs.Status.AutomaticRollback).To(Equal(&configsv1alpha1.AutomaticRollback{FailedRevision: bad, Revision: good}))
+			Expect(cs.Status.History[0].Result).To(Equal(configsv1alpha1.SyncResultFailed))
+
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			expectVersion("v1")
+			cs = getConfigSync(ctx, name)
+			Expect(cs.Status.SourceRevision).To(Equal(good))
+			Expect(meta.FindStatusCondition(cs.Status.Conditions, "RolledBack").Reason).To(Equal("AutomaticRollback"))
+
+			// A new revision resumes forward sync
+			commitFiles(repo, map[string]string{"manifests/deploy.yaml": deployment("api:fixed")})
+			syncVersion(repo, "v3")
+			expectVersion("v3")
+			cs = getConfigSync(ctx, name)
+			Expect(cs.Status.AutomaticRollback).To(BeNil())
+			Expect(meta.FindStatusCondition(cs.Status.Conditions, "RolledBack")).To(BeNil())
+		})
+	})
+
+	Context("When a ConfigSync impersonates a ServiceAccount", func() {
+		const name = "impersonating"
+
+		ctx := context.Background()
+		account := metav1.ObjectMeta{Name: "deployer", Namespace: "default"}
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, &corev1.ServiceAccount{ObjectMeta: account})).To(Succeed())
+			Expect(k8sClient.Create(ctx, &rbacv1.Role{
+				ObjectMeta: account,
+				Rules: []rbacv1.PolicyRule{{
+					APIGroups: []string{""},
+					Resources: []string{"configmaps"},
+					Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+				}},
+			})).To(Succeed())
+			Expect(k8sClient.Create(ctx, &rbacv1.RoleBinding{
+				ObjectMeta: account,
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: account.Name},
+				Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: account.Name, Namespace: account.Namespace}},
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &rbacv1.RoleBinding{ObjectMeta: account}))).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &rbacv1.Role{ObjectMeta: account})).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &corev1.ServiceAccount{ObjectMeta: account})).To(Succeed())
+		})
+
+		It("applies with the ServiceAccount's permissions only", func() {
+			repo := newGitRepo(map[string]string{
+				"manifests/cm.yaml":     configMapManifest(name, "key", "value"),
+				"manifests/secret.yaml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: " + name + "\nstringData:\n  key: value\n",
+			})
+			cs := newConfigSync(name, repo, "manifests")
+			cs.Spec.ServiceAccountName = account.Name
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(errors.IsForbidden(err)).To(BeTrue(), "expected a Forbidden error, got %v", err)
+
+			By("applying the ConfigMap the ServiceAccount may manage")
+			_, err = getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("rejecting the Secret it may not create")
+			cs = getConfigSync(ctx, name)
+			degraded := meta.FindStatusCondition(cs.Status.Conditions, "Degraded")
+			Expect(degraded).NotTo(BeNil())
+			Expect(degraded.Reason).To(Equal("PermissionDenied"))
+			err = k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue(), "expected the Secret not to be created, got %v", err)
+		})
+
+		It("can be deleted once its RoleBinding is gone", func() {
+			repo := newGitRepo(map[string]string{
+				"manifests/cm.yaml": configMapManifest(name, "key", "value"),
+			})
+			cs := newConfigSync(name, repo, "manifests")
+			cs.Spec.ServiceAccountName = account.Name
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Delete(ctx, &rbacv1.RoleBinding{ObjectMeta: account})).To(Succeed())
+			impersonating := rest.CopyConfig(cfg)
+			impersonating.Impersonate = rest.ImpersonationConfig{UserName: "system:serviceaccount:default:" + account.Name}
+			saClient, err := client.New(impersonating, client.Options{Scheme: k8sClient.Scheme()})
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(func() bool {
+				err := saClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &corev1.ConfigMap{})
+				return errors.IsForbidden(err)
+			}).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, getConfigSync(ctx, name))).To(Succeed())
+			recorder := record.NewFakeRecorder(10)
+			r := &ConfigSyncReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Config: cfg, Recorder: recorder}
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &configsv1alpha1.ConfigSync{})
+			Expect(errors.IsNotFound(err)).To(BeTrue(), "expected ConfigSync to be gone, got %v", err)
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning CleanupFailed")))
+			_, err = getConfigMap(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Delete(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})).To(Succeed())
+		})
+	})
+
+	Context("When a ConfigSync deploys to a remote cluster", func() {
+		const name = "remote"
+
+		ctx := context.Background()
+		var remoteEnv *envtest.Environment
+		var remoteClient client.Client
+
+		BeforeEach(func() {
+			remoteEnv = &envtest.Environment{BinaryAssetsDirectory: testEnv.BinaryAssetsDirectory}
+			remoteCfg, err := remoteEnv.Start()
+			Expect(err).NotTo(HaveOccurred())
+			remoteClient, err = client.New(remoteCfg, client.Options{Scheme: k8sClient.Scheme()})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "remote-kubeconfig", Namespace: "default"},
+				Data:       map[string][]byte{"value": kubeConfigFor(remoteCfg)},
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			deleteConfigSync(ctx, name)
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "remote-kubeconfig", Namespace: "default"},
+			}))).To(Succeed())
+			if remoteEnv != nil {
+				Expect(remoteEnv.Stop()).To(Succeed())
+			}
+		})
+
+		It("applies to the remote cluster and reports when it becomes unreachable", func() {
+			repo := newGitRepo(map[string]string{
+				"manifests/cm.yaml": configMapManifest(name, "key", "value"),
+			})
+			cs := newConfigSync(name, repo, "manifests")
+			cs.Spec.KubeConfig = &configsv1alpha1.KubeConfigSpec{
+				SecretRef: configsv1alpha1.SecretKeyReference{Name: "remote-kubeconfig"},
+			}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+
+			remote := &corev1.ConfigMap{}
+			Expect(remoteClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, remote)).To(Succeed())
+			Expect(remote.Data).To(HaveKeyWithValue("key", "value"))
+			_, err = getConfigMap(ctx, name)
+			Expect(errors.IsNotFound(err)).To(BeTrue(), "expected nothing applied locally, got %v", err)
+
+			By("stopping the remote cluster")
+			Expect(remoteEnv.Stop()).To(Succeed())
+			remoteEnv = nil
+
+			_, err = reconcileConfigSync(ctx, name)
+			Expect(err).To(HaveOccurred())
+			cs = getConfigSync(ctx, name)
+			degraded := meta.FindStatusCondition(cs.Status.Conditions, "Degraded")
+			Expect(degraded).NotTo(BeNil())
+			Expect(degraded.Reason).To(Equal("RemoteClusterUnreachable"))
+
+			By("deleting the ConfigSync while the remote cluster is unreachable")
+			Expect(cs.Status.Inventory).NotTo(BeEmpty())
+			deleteConfigSync(ctx, name)
+		})
+
+		It("drops cached clients once the kubeconfig changes or the ConfigSync is deleted", func() {
+			repo := newGitRepo(map[string]string{
+				"manifests/cm.yaml": configMapManifest(name, "key", "value"),
+			})
+			cs := newConfigSync(name, repo, "manifests")
+			cs.Spec.KubeConfig = &configsv1alpha1.KubeConfigSpec{
+				SecretRef: configsv1alpha1.SecretKeyReference{Name: "remote-kubeconfig"},
+			}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+
+			r := &ConfigSyncReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Config: cfg}
+			request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}}
+			_, err := r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.clusterClients.len()).To(Equal(1))
+
+			By("rotating the kubeconfig")
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "remote-kubeconfig"}, secret)).To(Succeed())
+			secret.Data["value"] = append(secret.Data["value"], []byte("\n# rotated\n")...)
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+			_, err = r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.clusterClients.len()).To(Equal(1))
+
+			By("deleting the ConfigSync")
+			Expect(k8sClient.Delete(ctx, getConfigSync(ctx, name))).To(Succeed())
+			_, err = r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.clusterClients.len()).To(Equal(0))
+		})
+
+		It("can be deleted once its kubeconfig Secret is gone", func() {
+			repo := newGitRepo(map[string]string{
+				"manifests/cm.yaml": configMapManifest(name, "key", "value"),
+			})
+			cs := newConfigSync(name, repo, "manifests")
+			cs.Spec.KubeConfig = &configsv1alpha1.KubeConfigSpec{
+				SecretRef: configsv1alpha1.SecretKeyReference{Name: "remote-kubeconfig"},
+			}
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(getConfigSync(ctx, name).Status.Inventory).NotTo(BeEmpty())
+
+			Expect(k8sClient.Delete(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "remote-kubeconfig", Namespace: "default"},
+			})).To(Succeed())
+			Expect(k8sClient.Delete(ctx, getConfigSync(ctx, name))).To(Succeed())
+
+			recorder := record.NewFakeRecorder(10)
+			r := &ConfigSyncReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), Config: cfg, Recorder: recorder}
+			_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &configsv1alpha1.ConfigSync{})
+			Expect(errors.IsNotFound(err)).To(BeTrue(), "expected ConfigSync to be gone, got %v", err)
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning CleanupFailed")))
+
+			By("leaving the remote objects in place")
+			Expect(remoteClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, &corev1.ConfigMap{})).To(Succeed())
+		})
+	})
+
+	Context("When a kubeconfig is not self-contained", func() {
+		It("rejects kubeconfigs that run commands or read local files", func() {
+			for _, kubeConfig := range []string{
+				"users:\n- name: u\n  user:\n    exec:\n      apiVersion: client.authentication.k8s.io/v1\n      command: /bin/sh\n",
+				"users:\n- name: u\n  user:\n    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token\n",
+				"clusters:\n- name: c\n  cluster:\n    server: https://example.com\n    certificate-authority: /etc/ca.crt\n",
+			} {
+				_, err := restConfigFromKubeConfig([]byte(kubeConfig))
+				Expect(err).To(HaveOccurred(), "expected kubeconfig to be rejected:\n%s", kubeConfig)
+			}
+		})
+	})
+
+	Context("When a ConfigSync is deleted", func() {
+		ctx := context.Background()
+
+		AfterEach(func() {
+			for _, name := range []string{"cleanup-a", "cleanup-b"} {
+				deleteConfigSync(ctx, name)
+				_ = k8sClient.Delete(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})
+			}
+		})
+
+		// newCleanupRepo returns a repository with a ConfigMap manifest for
+		// each ConfigSync in its own directory.
+		newCleanupRepo := func() string {
+			return newGitRepo(map[string]string{
+				"cleanup-a/cm.yaml": configMapManifest("cleanup-a", "key", "value"),
+				"cleanup-b/cm.yaml": configMapManifest("cleanup-b", "key", "value"),
+			})
+		}
+
+		// syncWithPolicy creates and reconciles a ConfigSync that applies the
+		// ConfigMap named after it from repo.
+		syncWithPolicy := func(name, repo, policy string) {
+			cs := newConfigSync(name, repo, name)
+			cs.Spec.DeletionPolicy = policy
+			Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+			_, err := reconcileConfigSync(ctx, name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(getConfigSync(ctx, name).Finalizers).To(ContainElement(configSyncFinalizer))
+		}
+
+		// cacheDir returns the cached repository of a local repository path.
+		cacheDir := func(repo string) string {
+			return filepath.Join(os.TempDir(), "config-sync-cache", "repos", strings.ReplaceAll(repo, "/", "_"))
+		}
+
+		It("deletes applied objects with the Delete policy", func() {
+			repo := newCleanupRepo()
+			syncWithPolicy("cleanup-a", repo, configsv1alpha1.DeletionPolicyDelete)
+			_, err := getConfigMap(ctx, "cleanup-a")
+			Expect(err).NotTo(HaveOccurred())
+
+			deleteConfigSync(ctx, "cleanup-a")
+
+			_, err = getConfigMap(ctx, "cleanup-a")
+			Expect(errors.IsNotFound(err)).To(BeTrue(), "expected ConfigMap to be deleted, got %v", err)
+		})
+
+		It("leaves applied objects behind with the Orphan policy", func() {
+			repo := newCleanupRepo()
+			syncWithPolicy("cleanup-a", repo, configsv1alpha1.DeletionPolicyOrphan)
+
+			deleteConfigSync(ctx, "cleanup-a")
+
+			cm, err := getConfigMap(ctx, "cleanup-a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cm.Data).To(HaveKeyWithValue("key", "value"))
+			for _, entry := range cm.ManagedFields {
+				Expect(entry.Manager).NotTo(Equal(apply.FieldManager))
+			}
+		})
+
+		It("leaves applied objects untouched with the skip-cleanup annotation", func() {
+			repo := newCleanupRepo()
+			syncWithPolicy("cleanup-a", repo, configsv1alpha1.DeletionPolicyDelete)
+			cs := getConfigSync(ctx, "cleanup-a")
+			cs.Annotations = map[string]string{configsv1alpha1.SkipCleanupAnnotation: "true"}
+			Expect(k8sClient.Update(ctx, cs)).To(Succeed())
+
+			deleteConfigSync(ctx, "cleanup-a")
+
+			_, err := getConfigMap(ctx, "cleanup-a")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("keeps the cached clone while another ConfigSync uses the repository", func() {
+			repo := newCleanupRepo()
+			syncWithPolicy("cleanup-a", repo, configsv1alpha1.DeletionPolicyOrphan)
+			syncWithPolicy("cleanup-b", repo, configsv1alpha1.DeletionPolicyOrphan)
+			Expect(cacheDir(repo)).To(BeADirectory())
+
+			deleteConfigSync(ctx, "cleanup-a")
+			Expect(cacheDir(repo)).To(BeADirectory())
+
+			deleteConfigSync(ctx, "cleanup-b")
+			Expect(cacheDir(repo)).NotTo(BeAnExistingFile())
 		})
 	})
 })
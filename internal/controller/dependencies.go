@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// dependencyRequeueInterval is how often a ConfigSync waiting for its
+// dependencies checks them again, in case an event was missed.
+const dependencyRequeueInterval = 30 * time.Second
+
+// errDependencyCycle is returned when a ConfigSync depends on itself,
+// directly or through other ConfigSyncs.
+var errDependencyCycle = errors.New("dependency cycle")
+
+// dependencyKey returns the key of the ConfigSync ref points at from configSync.
+func dependencyKey(configSync *configsv1alpha1.ConfigSync, ref configsv1alpha1.DependencyReference) types.NamespacedName {
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = configSync.Namespace
+	}
+	return types.NamespacedName{Namespace: namespace, Name: ref.Name}
+}
+
+// dependencyReady reports whether dep was synced at its current generation
+// and is Ready.
+func dependencyReady(dep *configsv1alpha1.ConfigSync) bool {
+	return dep.Status.ObservedGeneration == dep.Generation &&
+		meta.IsStatusConditionTrue(dep.Status.Conditions, "Ready")
+}
+
+// checkDependencies returns a message naming the first dependency of
+// configSync that is missing or not ready, or "" once all of them are ready.
+// It fails with errDependencyCycle when configSync depends on itself.
+func (r *ConfigSyncReconciler) checkDependencies(ctx context.Context, configSync *configsv1alpha1.ConfigSync) (string, error) {
+	if err := r.findDependencyCycle(ctx, configSync); err != nil {
+		return "", err
+	}
+
+	for _, ref := range configSync.Spec.DependsOn {
+		key := dependencyKey(configSync, ref)
+		var dep configsv1alpha1.ConfigSync
+		if err := r.Get(ctx, key, &dep); err != nil {
+			if apierrors.IsNotFound(err) {
+				return fmt.Sprintf("Dependency %s not found", key), nil
+			}
+			return "", fmt.Errorf("failed to get dependency %s: %w", key, err)
+		}
+		if !dependencyReady(&dep) {
+			return fmt.Sprintf("Dependency %s is not ready", key), nil
+		}
+	}
+	return "", nil
+}
+
+// findDependencyCycle walks the dependencies of root and fails with
+// errDependencyCycle, naming the path, if they lead back to root. Missing
+// ConfigSyncs end the walk; they are reported by checkDependencies.
+func (r *ConfigSyncReconciler) findDependencyCycle(ctx context.Context, root *configsv1alpha1.ConfigSync) error {
+	rootKey := client.ObjectKeyFromObject(root)
+	visited := map[types.NamespacedName]bool{}
+
+	var visit func(cs *configsv1alpha1.ConfigSync, path []string) error
+	visit = func(cs *configsv1alpha1.ConfigSync, path []string) error {
+		for _, ref := range cs.Spec.DependsOn {
+			key := dependencyKey(cs, ref)
+			next := append(path[:len(path):len(path)], key.String())
+			if key == rootKey {
+				return fmt.Errorf("%w: %s", errDependencyCycle, strings.Join(next, " -> "))
+			}
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+
+			var dep configsv1alpha1.ConfigSync
+			if err := r.Get(ctx, key, &dep); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return fmt.Errorf("failed to get dependency %s: %w", key, err)
+			}
+			if err := visit(&dep, next); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(root, []string{rootKey.String()})
+}
+
+// configSyncsDependingOn maps a ConfigSync to the ConfigSyncs that list it in
+// spec.dependsOn, so that they are synced as soon as it turns ready.
+func (r *ConfigSyncReconciler) configSyncsDependingOn(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list configsv1alpha1.ConfigSyncList
+	if err := r.List(ctx, &list); err != nil {
+		logf.FromContext(ctx).Error(err, "failed to list ConfigSyncs", "dependency", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+
+	key := client.ObjectKeyFromObject(obj)
+	var requests []reconcile.Request
+	for _, cs := range list.Items {
+		for _, ref := range cs.Spec.DependsOn {
+			if dependencyKey(&cs, ref) == key {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&cs)})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// dependencyReadinessChanged passes the ConfigSync updates that can unblock
+// or block the ConfigSyncs depending on it.
+var dependencyReadinessChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldCS, ok := e.ObjectOld.(*configsv1alpha1.ConfigSync)
+		if !ok {
+			return true
+		}
+		newCS, ok := e.ObjectNew.(*configsv1alpha1.ConfigSync)
+		if !ok {
+			return true
+		}
+		return dependencyReady(oldCS) != dependencyReady(newCS)
+	},
+}
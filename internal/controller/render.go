@@ -0,0 +1,222 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/chart"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+	apply "github.com/joe-bresee/config-synchronizer-operator/internal/apply"
+	"github.com/joe-bresee/config-synchronizer-operator/internal/helm"
+	"github.com/joe-bresee/config-synchronizer-operator/internal/kustomize"
+	source "github.com/joe-bresee/config-synchronizer-operator/internal/sources"
+	tmpl "github.com/joe-bresee/config-synchronizer-operator/internal/template"
+)
+
+// targetRenderer produces the desired objects for a single target.
+type targetRenderer func(target configsv1alpha1.TargetRef) ([]*unstructured.Unstructured, error)
+
+// targetRenderer prepares the render stages configured in spec.render (loading
+// template values, running kustomize) once per reconcile and returns a
+// function rendering each target from them, together with a digest of the
+// inputs that were loaded from outside the ConfigSync spec.
+//
+// ConfigMap and Secret targets are always generated from the selected files.
+// Other targets use the kustomize or Helm output when spec.render.kustomize or
+// spec.render.helm is set and the (optionally templated) YAML files otherwise.
+func (r *ConfigSyncReconciler) targetRenderer(
+	ctx context.Context,
+	configSync *configsv1alpha1.ConfigSync,
+	repoRoot string,
+	files []string,
+	revision string,
+) (targetRenderer, string, error) {
+	renderSpec := configSync.Spec.Render
+	if renderSpec == nil {
+		renderSpec = &configsv1alpha1.RenderSpec{}
+	}
+
+	var values map[string]interface{}
+	if renderSpec.Template != nil {
+		var err error
+		values, err = tmpl.LoadValues(ctx, r.Client, configSync.Namespace, renderSpec.Template)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load template values: %w", err)
+		}
+	}
+
+	if renderSpec.Kustomize != nil && renderSpec.Helm != nil {
+		return nil, "", fmt.Errorf("spec.render.kustomize and spec.render.helm are mutually exclusive")
+	}
+
+	var built []byte
+	inputs := map[string]interface{}{}
+	if values != nil {
+		inputs["template"] = values
+	}
+	if renderSpec.Helm != nil {
+		var helmValues map[string]interface{}
+		var chartID string
+		var err error
+		built, helmValues, chartID, err = r.renderHelm(ctx, configSync, repoRoot)
+		if err != nil {
+			return nil, "", err
+		}
+		inputs["helm"] = helmValues
+		inputs["chart"] = chartID
+	}
+
+	digest, err := renderDigest(inputs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if renderSpec.Kustomize != nil {
+		selectedPath, _, _ := source.Selection(&configSync.Spec.Source)
+		dir, err := source.ResolvePath(repoRoot, selectedPath)
+		if err != nil {
+			return nil, "", err
+		}
+		built, err = kustomize.Build(repoRoot, dir, renderSpec.Kustomize)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return func(target configsv1alpha1.TargetRef) ([]*unstructured.Unstructured, error) {
+		isGenerated := target.Type == apply.TargetTypeConfigMap || target.Type == apply.TargetTypeSecret
+		if built != nil && !isGenerated {
+			return apply.ParseManifests(built, "rendered manifests", target.Namespace)
+		}
+
+		var render apply.FileRenderer
+		if renderSpec.Template != nil {
+			data := tmpl.Data{
+				Values:     values,
+				ConfigSync: tmpl.ObjectInfo{Name: configSync.Name, Namespace: configSync.Namespace},
+				Target:     tmpl.TargetInfo{Name: target.Name, Namespace: target.Namespace, Type: target.Type},
+				Revision:   revision,
+			}
+			render = func(path string, content []byte) ([]byte, error) {
+				return tmpl.RenderFile(path, content, data)
+			}
+		}
+		return apply.RenderTarget(files, target, render)
+	}, digest, nil
+}
+
+// renderHelm loads the chart configured in spec.render.helm, merges its values
+// and renders it. Along with the manifests it returns the merged values and
+// the rendered chart's name and version, which feed the render digest.
+func (r *ConfigSyncReconciler) renderHelm(
+	ctx context.Context,
+	configSync *configsv1alpha1.ConfigSync,
+	repoRoot string,
+) ([]byte, map[string]interface{}, string, error) {
+	spec := configSync.Spec.Render.Helm
+
+	var chrt *chart.Chart
+	var err error
+	if spec.Chart != nil {
+		var creds *helm.Credentials
+		creds, err = r.helmCredentials(ctx, configSync.Namespace, spec.Chart.AuthSecretRef)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		chrt, err = helm.PullChart(ctx, spec.Chart, creds)
+	} else {
+		selectedPath, _, _ := source.Selection(&configSync.Spec.Source)
+		chrt, err = helm.LoadChartDir(repoRoot, selectedPath)
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	values, err := helm.LoadValuesFiles(repoRoot, spec.ValuesFiles)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	referenced, err := tmpl.LoadValuesFrom(ctx, r.Client, configSync.Namespace, spec.ValuesFrom, spec.Values)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to load chart values: %w", err)
+	}
+	values = tmpl.MergeValues(values, referenced)
+
+	opts := helm.Options{
+		ReleaseName: spec.ReleaseName,
+		Namespace:   spec.Namespace,
+		Values:      values,
+		IncludeCRDs: spec.IncludeCRDs,
+	}
+	if opts.ReleaseName == "" {
+		opts.ReleaseName = configSync.Name
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = configSync.Namespace
+	}
+	out, err := helm.Render(ctx, chrt, opts)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return out, values, chrt.Name() + "-" + chrt.Metadata.Version, nil
+}
+
+// helmCredentials reads the chart repository credentials referenced by ref
+// from namespace, the ConfigSync's own namespace; Secrets in other namespaces
+// are refused. It returns nil when ref is nil.
+func (r *ConfigSyncReconciler) helmCredentials(ctx context.Context, namespace string, ref *configsv1alpha1.ObjectRef) (*helm.Credentials, error) {
+	if ref == nil {
+		return nil, nil
+	}
+	if ref.Namespace != "" && ref.Namespace != namespace {
+		return nil, fmt.Errorf("chart repository Secret %s/%s must be in the ConfigSync namespace %s", ref.Namespace, ref.Name, namespace)
+	}
+
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+		return nil, fmt.Errorf("failed to read chart repository Secret %s/%s: %w", namespace, ref.Name, err)
+	}
+	username, password := string(secret.Data["username"]), string(secret.Data["password"])
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("secret %s/%s missing username/password for chart repository auth", namespace, ref.Name)
+	}
+	return &helm.Credentials{Username: username, Password: password}, nil
+}
+
+// renderDigest returns a stable digest of the render inputs loaded outside of
+// the ConfigSync spec, or an empty string when there are none.
+func renderDigest(inputs map[string]interface{}) (string, error) {
+	if len(inputs) == 0 {
+		return "", nil
+	}
+	// encoding/json sorts map keys, so equal inputs produce equal digests
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash render inputs: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+	source "github.com/joe-bresee/config-synchronizer-operator/internal/sources"
+)
+
+// maxHistory bounds the number of records kept in status.history.
+const maxHistory = 10
+
+// errRollbackFailed wraps failures to fetch the revision a ConfigSync rolls
+// back to.
+var errRollbackFailed = errors.New("rollback failed")
+
+// fetchSource fetches the revision to sync: the one selected by spec.rollback
+// when set, otherwise the source's latest revision, unless that is the
+// revision an automatic rollback moved away from. The automatic rollback ends
+// once the source moves past it.
+func (r *ConfigSyncReconciler) fetchSource(ctx context.Context, configSync *configsv1alpha1.ConfigSync) (*source.Result, error) {
+	if configSync.Spec.Rollback != nil {
+		return r.fetchRevision(ctx, configSync, configSync.Spec.Rollback.Revision)
+	}
+
+	fetched, err := source.FetchSource(configSync, ctx, r.Client, r.SourceOptions)
+	rollback := configSync.Status.AutomaticRollback
+	if err != nil || rollback == nil {
+		return fetched, err
+	}
+	if fetched.Revision != rollback.FailedRevision {
+		logf.FromContext(ctx).Info("Source moved past the rolled back revision — resuming", "failed", rollback.FailedRevision, "new", fetched.Revision)
+		configSync.Status.AutomaticRollback = nil
+		return fetched, nil
+	}
+	if configSync.Spec.Source.Git == nil {
+		_ = os.RemoveAll(fetched.Path)
+	}
+	return r.fetchRevision(ctx, configSync, rollback.Revision)
+}
+
+// fetchRevision fetches revision of configSync's source.
+func (r *ConfigSyncReconciler) fetchRevision(ctx context.Context, configSync *configsv1alpha1.ConfigSync, revision string) (*source.Result, error) {
+	pinned := configSync.DeepCopy()
+	if err := source.PinRevision(&pinned.Spec.Source, revision); err != nil {
+		return nil, fmt.Errorf("%w: %w", errRollbackFailed, err)
+	}
+	fetched, err := source.FetchSource(pinned, ctx, r.Client, r.SourceOptions)
+	if err != nil {
+		return nil, fmt.Errorf("%w: revision %s: %w", errRollbackFailed, revision, err)
+	}
+	return fetched, nil
+}
+
+// setRollbackCondition reports whether configSync is rolled back, and why.
+func setRollbackCondition(configSync *configsv1alpha1.ConfigSync) {
+	status := &configSync.Status
+	switch rollback := status.AutomaticRollback; {
+	case configSync.Spec.Rollback != nil:
+		setCondition(status, "RolledBack", metav1.ConditionTrue, "ManualRollback",
+			fmt.Sprintf("Rolled back to revision %s by spec.rollback; forward sync is paused", configSync.Spec.Rollback.Revision))
+	case rollback != nil:
+		setCondition(status, "RolledBack", metav1.ConditionTrue, "AutomaticRollback",
+			fmt.Sprintf("Revision %s failed its health checks; rolled back to %s until the source moves past it",
+				rollback.FailedRevision, rollback.Revision))
+	default:
+		meta.RemoveStatusCondition(&status.Conditions, "RolledBack")
+	}
+}
+
+// recordSync adds record to the front of status.history. A record for the
+// same revision as the newest one replaces it, so that retries don't flood
+// the history.
+func recordSync(status *configsv1alpha1.ConfigSyncStatus, record configsv1alpha1.SyncRecord) {
+	if len(status.History) > 0 && status.History[0].Revision == record.Revision {
+		status.History = status.History[1:]
+	}
+	status.History = append([]configsv1alpha1.SyncRecord{record}, status.History...)
+	if len(status.History) > maxHistory {
+		status.History = status.History[:maxHistory]
+	}
+}
+
+// newSyncRecord describes fetched as applied to objects objects now.
+func newSyncRecord(configSync *configsv1alpha1.ConfigSync, fetched *source.Result, objects int, result string) configsv1alpha1.SyncRecord {
+	message, _, _ := strings.Cut(strings.TrimSpace(fetched.Message), "\n")
+	return configsv1alpha1.SyncRecord{
+		Revision:    fetched.Revision,
+		Message:     message,
+		Author:      fetched.Author,
+		AppliedTime: metav1.Time{Time: time.Now()},
+		Result:      result,
+		Objects:     objects,
+		Rollback:    configSync.Spec.Rollback != nil || configSync.Status.AutomaticRollback != nil,
+	}
+}
+
+// recordHealth records the outcome of the health checks of the synced
+// revision in its history record. When a newly applied revision fails them
+// and spec.wait.rollbackOnFailure is set, it starts an automatic rollback to
+// the newest revision that became healthy and returns true.
+func recordHealth(configSync *configsv1alpha1.ConfigSync) bool {
+	status := &configSync.Status
+	if len(status.History) == 0 || status.History[0].Revision != status.SourceRevision {
+		return false
+	}
+	latest := &status.History[0]
+
+	ready := meta.FindStatusCondition(status.Conditions, "Ready")
+	switch {
+	case ready == nil:
+		return false
+	case ready.Status == metav1.ConditionTrue:
+		latest.Result = configsv1alpha1.SyncResultHealthy
+		return false
+	case latest.Result != configsv1alpha1.SyncResultApplied:
+		return false
+	case ready.Reason != "HealthCheckFailed" && ready.Reason != "HealthCheckTimeout":
+		return false
+	}
+	latest.Result = configsv1alpha1.SyncResultFailed
+
+	wait := configSync.Spec.Wait
+	if wait == nil || !wait.RollbackOnFailure || configSync.Spec.Rollback != nil || status.AutomaticRollback != nil {
+		return false
+	}
+	for _, record := range status.History[1:] {
+		if record.Result == configsv1alpha1.SyncResultHealthy && record.Revision != latest.Revision {
+			status.AutomaticRollback = &configsv1alpha1.AutomaticRollback{FailedRevision: latest.Revision, Revision: record.Revision}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,331 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+	source "github.com/joe-bresee/config-synchronizer-operator/internal/sources"
+	tmpl "github.com/joe-bresee/config-synchronizer-operator/internal/template"
+)
+
+const (
+	// downloadTimeout bounds each request to a chart repository or registry.
+	downloadTimeout = 2 * time.Minute
+	// maxCachedCharts bounds the number of chart archives kept in memory.
+	maxCachedCharts = 32
+)
+
+// httpClient is used for every chart repository and registry request.
+var httpClient = &http.Client{Timeout: downloadTimeout}
+
+// charts caches pulled chart archives so that a chart version is only
+// downloaded once, rather than on every reconcile.
+var charts = &chartCache{entries: map[string][]byte{}}
+
+// chartCache holds chart archives keyed by repository, chart, resolved version
+// and credentials. The oldest entries are evicted first.
+type chartCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	order   []string
+}
+
+func (c *chartCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.entries[key]
+	return data, ok
+}
+
+func (c *chartCache) add(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	c.entries[key] = data
+	c.order = append(c.order, key)
+	if len(c.order) > maxCachedCharts {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// chartKey identifies the archive of chart name at version in repoURL. The
+// credentials are part of the key so that a chart pulled with one set of
+// credentials is never served to a ConfigSync that lacks them.
+func chartKey(repoURL, name, version string, creds *Credentials) string {
+	h := sha256.New()
+	for _, part := range []string{repoURL, name, version} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	if creds != nil {
+		h.Write([]byte(creds.Username))
+		h.Write([]byte{0})
+		h.Write([]byte(creds.Password))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Credentials authenticate against a chart repository or OCI registry.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Options configure how a chart is rendered.
+type Options struct {
+	// ReleaseName is exposed to templates as `.Release.Name`.
+	ReleaseName string
+	// Namespace is exposed to templates as `.Release.Namespace`.
+	Namespace string
+	// Values are merged over the chart's default values.
+	Values map[string]interface{}
+	// IncludeCRDs adds the chart's crds/ directory to the output.
+	IncludeCRDs bool
+}
+
+// LoadChartDir loads the chart stored at the repository-relative path within
+// root. Files are selected with source.CollectFiles, so symlinks escaping the
+// repository are rejected like for any other source path.
+func LoadChartDir(root, path string) (*chart.Chart, error) {
+	dir, err := source.ResolvePath(root, path)
+	if err != nil {
+		return nil, err
+	}
+	files, err := source.CollectFiles(root, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := make([]*loader.BufferedFile, 0, len(files))
+	for _, f := range files {
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve chart file %s: %w", f, err)
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chart file %s: %w", f, err)
+		}
+		buffered = append(buffered, &loader.BufferedFile{Name: filepath.ToSlash(rel), Data: data})
+	}
+
+	chrt, err := loader.LoadFiles(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s: %w", path, err)
+	}
+	return chrt, nil
+}
+
+// LoadValuesFiles reads the repository-relative values files within root and
+// merges them in order, later files taking precedence.
+func LoadValuesFiles(root string, files []string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for _, f := range files {
+		resolved, err := source.ResolvePath(root, f)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file %s: %w", f, err)
+		}
+		parsed := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse values file %s: %w", f, err)
+		}
+		values = tmpl.MergeValues(values, parsed)
+	}
+	return values, nil
+}
+
+// PullChart downloads the chart described by spec. RepoURL is either an
+// `oci://` registry path or an HTTP(S) chart repository serving index.yaml.
+// spec.Version may be an exact version or a semver constraint; the newest
+// matching version is used, and the newest overall when it is empty. Once the
+// version is resolved, the chart archive is served from an in-memory cache
+// when it was pulled before.
+func PullChart(ctx context.Context, spec *configsv1alpha1.HelmChartSource, creds *Credentials) (*chart.Chart, error) {
+	if strings.HasPrefix(spec.RepoURL, "oci://") {
+		return pullOCI(spec, creds)
+	}
+	return pullHTTP(ctx, spec, creds)
+}
+
+// pullHTTP resolves the chart version from the repository index and downloads
+// its archive.
+func pullHTTP(ctx context.Context, spec *configsv1alpha1.HelmChartSource, creds *Credentials) (*chart.Chart, error) {
+	base, err := url.Parse(strings.TrimSuffix(spec.RepoURL, "/") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid chart repository URL %q: %w", spec.RepoURL, err)
+	}
+
+	data, err := download(ctx, base.JoinPath("index.yaml").String(), creds)
+	if err != nil {
+		return nil, err
+	}
+	index := &repo.IndexFile{}
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to parse index of %s: %w", spec.RepoURL, err)
+	}
+	index.SortEntries()
+
+	version, err := index.Get(spec.Name, spec.Version)
+	if err != nil {
+		return nil, fmt.Errorf("chart %s %s not found in %s: %w", spec.Name, spec.Version, spec.RepoURL, err)
+	}
+	if len(version.URLs) == 0 {
+		return nil, fmt.Errorf("chart %s %s in %s has no download URL", spec.Name, version.Version, spec.RepoURL)
+	}
+
+	key := chartKey(spec.RepoURL, spec.Name, version.Version, creds)
+	archive, ok := charts.get(key)
+	if !ok {
+		chartURL, err := base.Parse(version.URLs[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid download URL for chart %s %s: %w", spec.Name, version.Version, err)
+		}
+		if archive, err = download(ctx, chartURL.String(), creds); err != nil {
+			return nil, err
+		}
+		charts.add(key, archive)
+	}
+	chrt, err := loader.LoadArchive(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s %s: %w", spec.Name, version.Version, err)
+	}
+	return chrt, nil
+}
+
+// download fetches rawURL, sending creds as basic auth when set.
+func download(ctx context.Context, rawURL string, creds *Credentials) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request for %s: %w", rawURL, err)
+	}
+	if creds != nil {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", rawURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	return data, nil
+}
+
+// pullOCI pulls the chart from an OCI registry, resolving semver constraints
+// against the repository's tags.
+func pullOCI(spec *configsv1alpha1.HelmChartSource, creds *Credentials) (*chart.Chart, error) {
+	opts := []registry.ClientOption{registry.ClientOptWriter(io.Discard), registry.ClientOptHTTPClient(httpClient)}
+	if creds != nil {
+		opts = append(opts, registry.ClientOptBasicAuth(creds.Username, creds.Password))
+	}
+	client, err := registry.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+
+	ref := strings.TrimSuffix(strings.TrimPrefix(spec.RepoURL, "oci://"), "/") + "/" + spec.Name
+	version := spec.Version
+	if _, err := semver.StrictNewVersion(version); err != nil {
+		constraint, err := semver.NewConstraint(orAny(version))
+		if err != nil {
+			return nil, fmt.Errorf("invalid chart version %q: %w", spec.Version, err)
+		}
+		tags, err := client.Tags(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of %s: %w", ref, err)
+		}
+		version = ""
+		// Tags are sorted newest first
+		for _, tag := range tags {
+			if v, err := semver.NewVersion(tag); err == nil && constraint.Check(v) {
+				version = tag
+				break
+			}
+		}
+		if version == "" {
+			return nil, fmt.Errorf("no tag of %s matches version %q", ref, spec.Version)
+		}
+	}
+
+	key := chartKey(spec.RepoURL, spec.Name, version, creds)
+	archive, ok := charts.get(key)
+	if !ok {
+		result, err := client.Pull(ref + ":" + version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pull chart %s:%s: %w", ref, version, err)
+		}
+		archive = result.Chart.Data
+		charts.add(key, archive)
+	}
+	chrt, err := loader.LoadArchive(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s:%s: %w", ref, version, err)
+	}
+	return chrt, nil
+}
+
+func orAny(constraint string) string {
+	if constraint == "" {
+		return "*"
+	}
+	return constraint
+}
+
+// Render renders chrt the way `helm template` does and returns the resulting
+// manifests as a multi-document YAML stream. No release is created and the
+// cluster is never contacted, so `lookup` returns nothing. Hooks and tests are
+// left out of the output.
+func Render(ctx context.Context, chrt *chart.Chart, opts Options) ([]byte, error) {
+	install := action.NewInstall(&action.Configuration{Log: func(string, ...interface{}) {}})
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.DisableHooks = true
+	install.ReleaseName = opts.ReleaseName
+	install.Namespace = opts.Namespace
+	install.IncludeCRDs = opts.IncludeCRDs
+
+	values := opts.Values
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	rel, err := install.RunWithContext(ctx, chrt, values)
+	if err != nil {
+		return nil, fmt.Errorf("helm render of chart %s failed: %w", chrt.Name(), err)
+	}
+	return []byte(rel.Manifest), nil
+}
@@ -0,0 +1,213 @@
+package helm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+const configMapTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-settings
+  namespace: {{ .Release.Namespace }}
+data:
+  greeting: {{ .Values.greeting | quote }}
+`
+
+func writeChart(t *testing.T, root, dir, version string) {
+	t.Helper()
+	files := map[string]string{
+		"Chart.yaml":                    "apiVersion: v2\nname: demo\nversion: " + version + "\n",
+		"values.yaml":                   "greeting: hello\n",
+		"templates/configmap.yaml":      configMapTemplate,
+		"templates/tests/test-pod.yaml": "apiVersion: v1\nkind: Pod\nmetadata:\n  name: test\n  annotations:\n    helm.sh/hook: test\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRenderChartDir(t *testing.T) {
+	root := t.TempDir()
+	writeChart(t, root, "charts/demo", "1.0.0")
+
+	chrt, err := LoadChartDir(root, "charts/demo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := Render(context.Background(), chrt, Options{
+		ReleaseName: "web",
+		Namespace:   "apps",
+		Values:      map[string]interface{}{"greeting": "hi"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{"name: web-settings", "namespace: apps", `greeting: "hi"`} {
+		if !strings.Contains(string(out), want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(string(out), "kind: Pod") {
+		t.Fatalf("expected hooks to be left out:\n%s", out)
+	}
+}
+
+func TestLoadChartDirRejectsPathOutsideRepository(t *testing.T) {
+	root := t.TempDir()
+	writeChart(t, filepath.Dir(root), "outside", "1.0.0")
+
+	if _, err := LoadChartDir(root, "../outside"); err == nil {
+		t.Fatal("expected an error for a chart outside of the repository")
+	}
+}
+
+func TestLoadValuesFiles(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"base.yaml": "greeting: hello\nreplicas: 1\n",
+		"prod.yaml": "replicas: 3\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	values, err := LoadValuesFiles(root, []string{"base.yaml", "prod.yaml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if values["greeting"] != "hello" || values["replicas"] != float64(3) {
+		t.Fatalf("unexpected values: %v", values)
+	}
+}
+
+// serveChartRepository serves an index.yaml with the given chart versions and
+// their archives, requiring basic auth when username is set.
+func serveChartRepository(t *testing.T, username, password string, versions ...string) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	for _, v := range versions {
+		src := t.TempDir()
+		writeChart(t, src, "demo", v)
+		if _, err := chartutil.Save(mustLoad(t, src, "demo"), dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index, err := repo.IndexDirectory(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.WriteFile(filepath.Join(dir, "index.yaml"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files := http.FileServer(http.Dir(dir))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username != "" {
+			if u, p, ok := r.BasicAuth(); !ok || u != username || p != password {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func mustLoad(t *testing.T, root, dir string) *chart.Chart {
+	t.Helper()
+	chrt, err := LoadChartDir(root, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chrt
+}
+
+func TestPullChartFromRepository(t *testing.T) {
+	srv := serveChartRepository(t, "", "", "1.0.0", "1.2.0", "2.0.0")
+
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{version: "1.0.0", want: "1.0.0"},
+		{version: "^1.0.0", want: "1.2.0"},
+		{version: "", want: "2.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			chrt, err := PullChart(context.Background(), &configsv1alpha1.HelmChartSource{
+				RepoURL: srv.URL,
+				Name:    "demo",
+				Version: tt.version,
+			}, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if chrt.Metadata.Version != tt.want {
+				t.Fatalf("expected version %s, got %s", tt.want, chrt.Metadata.Version)
+			}
+		})
+	}
+}
+
+func TestPullChartWithCredentials(t *testing.T) {
+	srv := serveChartRepository(t, "user", "secret", "1.0.0")
+	spec := &configsv1alpha1.HelmChartSource{RepoURL: srv.URL, Name: "demo"}
+
+	if _, err := PullChart(context.Background(), spec, nil); err == nil {
+		t.Fatal("expected an error without credentials")
+	}
+	if _, err := PullChart(context.Background(), spec, &Credentials{Username: "user", Password: "secret"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPullChartCachesArchives(t *testing.T) {
+	srv := serveChartRepository(t, "", "", "1.0.0")
+	var archives int
+	handler := srv.Config.Handler
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".tgz") {
+			archives++
+		}
+		handler.ServeHTTP(w, r)
+	})
+	spec := &configsv1alpha1.HelmChartSource{RepoURL: srv.URL, Name: "demo", Version: "1.0.0"}
+
+	for range 2 {
+		if _, err := PullChart(context.Background(), spec, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if archives != 1 {
+		t.Fatalf("expected the chart archive to be downloaded once, got %d downloads", archives)
+	}
+	if _, err := PullChart(context.Background(), spec, &Credentials{Username: "user", Password: "secret"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if archives != 2 {
+		t.Fatalf("expected a pull with other credentials to bypass the cache, got %d downloads", archives)
+	}
+}
@@ -0,0 +1,322 @@
+package kustomize
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+const (
+	// sourceRoot is where the kustomization roots are mounted in the in-memory
+	// filesystem, at their path within the repository.
+	sourceRoot = "/source"
+	// overlayRoot holds the generated overlay carrying the overrides.
+	overlayRoot = "/overlay"
+)
+
+// Build runs an in-process kustomize build of dir, which must lie within the
+// repository at root, and returns the rendered objects as a multi-document
+// YAML stream.
+//
+// The build runs against an in-memory copy of the kustomization in dir and of
+// the bases and components it refers to, so files outside of them cannot be
+// read and the rest of the repository is never loaded. Remote resources (Git
+// or HTTP URLs) and references leaving root are rejected before the build
+// starts because kustomize would fetch them by shelling out to git. Plugins
+// are disabled and files are only loaded from within the kustomization roots.
+// When opts carries any overrides, they are applied through a generated
+// overlay that uses dir as its only resource.
+func Build(root, dir string, opts *configsv1alpha1.KustomizeRender) ([]byte, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve repository root: %w", err)
+	}
+	rel, err := filepath.Rel(realRoot, dir)
+	if err != nil || !filepath.IsLocal(rel) {
+		return nil, fmt.Errorf("kustomize path %s is outside of the repository", dir)
+	}
+
+	var roots []string
+	if err := checkLocal(realRoot, filepath.Join(realRoot, rel), map[string]bool{}, &roots); err != nil {
+		return nil, err
+	}
+	fSys, err := loadRoots(realRoot, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	buildRoot := path.Join(sourceRoot, filepath.ToSlash(rel))
+	if hasOverrides(opts) {
+		if err := writeOverlay(fSys, buildRoot, opts); err != nil {
+			return nil, err
+		}
+		buildRoot = overlayRoot
+	}
+
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resMap, err := k.Run(fSys, buildRoot)
+	if err != nil {
+		return nil, fmt.Errorf("kustomize build failed: %w", err)
+	}
+
+	out, err := resMap.AsYaml()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode kustomize output: %w", err)
+	}
+	return out, nil
+}
+
+// loadRoots copies the directories in roots, which lie within the repository
+// at root, into an in-memory filesystem under sourceRoot. Symlinked files are
+// copied only when their target stays inside root; symlinked directories and
+// .git directories are skipped.
+func loadRoots(root string, roots []string) (filesys.FileSystem, error) {
+	fSys := filesys.MakeFsInMemory()
+	copied := map[string]bool{}
+
+	for _, dir := range roots {
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			target := path.Join(sourceRoot, filepath.ToSlash(rel))
+
+			if d.IsDir() {
+				if d.Name() == ".git" || copied[p] {
+					return filepath.SkipDir
+				}
+				copied[p] = true
+				return fSys.MkdirAll(target)
+			}
+
+			src := p
+			if d.Type()&fs.ModeSymlink != 0 {
+				resolved, ok := within(root, p)
+				if !ok {
+					return nil
+				}
+				info, err := os.Stat(resolved)
+				if err != nil || !info.Mode().IsRegular() {
+					return nil
+				}
+				src = resolved
+			} else if !d.Type().IsRegular() {
+				return nil
+			}
+
+			data, err := os.ReadFile(src)
+			if err != nil {
+				return err
+			}
+			return fSys.WriteFile(target, data)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s for kustomize: %w", displayPath(root, dir), err)
+		}
+	}
+	return fSys, nil
+}
+
+// within resolves the symlinks in p and reports whether the result exists and
+// lies inside root.
+func within(root, p string) (string, bool) {
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || (rel != "." && !filepath.IsLocal(rel)) {
+		return "", false
+	}
+	return resolved, true
+}
+
+// checkLocal walks the kustomization in dir and every kustomization it refers
+// to, adding their directories to roots, and fails on references that
+// kustomize would fetch remotely. Resources, components and bases must exist
+// within root, so Git URLs and paths escaping the repository are rejected;
+// file references must not be HTTP(S) URLs.
+func checkLocal(root, dir string, visited map[string]bool, roots *[]string) error {
+	if visited[dir] {
+		return nil
+	}
+	visited[dir] = true
+	*roots = append(*roots, dir)
+
+	var kustFile string
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if p := filepath.Join(dir, name); fileExists(p) {
+			kustFile = p
+			break
+		}
+	}
+	if kustFile == "" {
+		// kustomize reports the missing kustomization itself
+		return nil
+	}
+
+	data, err := os.ReadFile(kustFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", displayPath(root, kustFile), err)
+	}
+	var k types.Kustomization
+	if err := yaml.Unmarshal(data, &k); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", displayPath(root, kustFile), err)
+	}
+
+	var dirs []string
+	for _, ref := range append(append(append([]string{}, k.Resources...), k.Components...), k.Bases...) {
+		resolved, ok := within(root, filepath.Join(dir, ref))
+		if isURL(ref) || !ok {
+			return fmt.Errorf("%s: resource %q is remote or outside of the repository", displayPath(root, kustFile), ref)
+		}
+		if isDir(resolved) {
+			dirs = append(dirs, resolved)
+		}
+	}
+	for _, ref := range append(append(append([]string{}, k.Generators...), k.Transformers...), k.Validators...) {
+		if strings.Contains(ref, "\n") {
+			// inline plugin configuration
+			continue
+		}
+		resolved, ok := within(root, filepath.Join(dir, ref))
+		if isURL(ref) || !ok {
+			return fmt.Errorf("%s: plugin %q is remote or outside of the repository", displayPath(root, kustFile), ref)
+		}
+		if isDir(resolved) {
+			dirs = append(dirs, resolved)
+		}
+	}
+
+	files := append(append([]string{}, k.Crds...), k.Configurations...)
+	files = append(files, k.OpenAPI["path"])
+	for _, p := range k.PatchesStrategicMerge {
+		files = append(files, string(p))
+	}
+	for _, p := range k.Patches {
+		files = append(files, p.Path)
+	}
+	for _, r := range k.Replacements {
+		files = append(files, r.Path)
+	}
+	for _, g := range k.ConfigMapGenerator {
+		files = append(files, generatorFiles(g.KvPairSources)...)
+	}
+	for _, g := range k.SecretGenerator {
+		files = append(files, generatorFiles(g.KvPairSources)...)
+	}
+	for _, f := range files {
+		if isURL(f) {
+			return fmt.Errorf("%s: remote file %q is not supported", displayPath(root, kustFile), f)
+		}
+	}
+
+	for _, d := range dirs {
+		if err := checkLocal(root, d, visited, roots); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func isDir(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.IsDir()
+}
+
+// generatorFiles returns the file paths read by a ConfigMap or Secret generator.
+// File sources may carry a `key=` prefix, which is stripped.
+func generatorFiles(src types.KvPairSources) []string {
+	files := append(append([]string{}, src.EnvSources...), src.EnvSource)
+	for _, f := range src.FileSources {
+		if _, p, ok := strings.Cut(f, "="); ok {
+			f = p
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// isURL reports whether ref carries a URL scheme, as used by remote Git and
+// HTTP references.
+func isURL(ref string) bool {
+	u, err := url.Parse(ref)
+	return (err == nil && u.Scheme != "") || strings.HasPrefix(ref, "git@")
+}
+
+// displayPath returns p relative to the repository root for error messages.
+func displayPath(root, p string) string {
+	if rel, err := filepath.Rel(root, p); err == nil && filepath.IsLocal(rel) {
+		return filepath.ToSlash(rel)
+	}
+	return p
+}
+
+func hasOverrides(opts *configsv1alpha1.KustomizeRender) bool {
+	return opts != nil &&
+		(opts.Namespace != "" || opts.NamePrefix != "" || len(opts.CommonLabels) > 0 || len(opts.Images) > 0)
+}
+
+// writeOverlay writes a kustomization carrying the overrides to overlayRoot in
+// fSys, using base as its only resource.
+func writeOverlay(fSys filesys.FileSystem, base string, opts *configsv1alpha1.KustomizeRender) error {
+	// kustomize only accepts resources relative to the kustomization root
+	relBase, err := filepath.Rel(overlayRoot, base)
+	if err != nil {
+		return fmt.Errorf("failed to resolve kustomize base: %w", err)
+	}
+
+	kustomization := types.Kustomization{
+		TypeMeta: types.TypeMeta{
+			APIVersion: types.KustomizationVersion,
+			Kind:       types.KustomizationKind,
+		},
+		Resources:  []string{filepath.ToSlash(relBase)},
+		Namespace:  opts.Namespace,
+		NamePrefix: opts.NamePrefix,
+	}
+	if len(opts.CommonLabels) > 0 {
+		kustomization.Labels = []types.Label{{Pairs: opts.CommonLabels, IncludeSelectors: true}}
+	}
+	for _, img := range opts.Images {
+		kustomization.Images = append(kustomization.Images, types.Image{
+			Name:    img.Name,
+			NewName: img.NewName,
+			NewTag:  img.NewTag,
+			Digest:  img.Digest,
+		})
+	}
+
+	data, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return fmt.Errorf("failed to encode kustomize overlay: %w", err)
+	}
+	if err := fSys.MkdirAll(overlayRoot); err != nil {
+		return fmt.Errorf("failed to create kustomize overlay: %w", err)
+	}
+	if err := fSys.WriteFile(path.Join(overlayRoot, "kustomization.yaml"), data); err != nil {
+		return fmt.Errorf("failed to write kustomize overlay: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.25
+`
+
+func writeKustomization(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"kustomization.yaml": "resources:\n- deployment.yaml\n",
+		"deployment.yaml":    deployment,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestBuild(t *testing.T) {
+	dir := writeKustomization(t)
+
+	out, err := Build(dir, dir, &configsv1alpha1.KustomizeRender{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(out), "name: web") || !strings.Contains(string(out), "image: nginx:1.25") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestBuildWithOverrides(t *testing.T) {
+	dir := writeKustomization(t)
+
+	out, err := Build(dir, dir, &configsv1alpha1.KustomizeRender{
+		Namespace:    "prod",
+		NamePrefix:   "team-a-",
+		CommonLabels: map[string]string{"env": "prod"},
+		Images:       []configsv1alpha1.KustomizeImage{{Name: "nginx", NewTag: "1.27"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var obj struct {
+		Metadata struct {
+			Name      string            `json:"name"`
+			Namespace string            `json:"namespace"`
+			Labels    map[string]string `json:"labels"`
+		} `json:"metadata"`
+		Spec struct {
+			Selector struct {
+				MatchLabels map[string]string `json:"matchLabels"`
+			} `json:"selector"`
+			Template struct {
+				Spec struct {
+					Containers []struct {
+						Image string `json:"image"`
+					} `json:"containers"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	if err := yaml.Unmarshal(out, &obj); err != nil {
+		t.Fatalf("failed to parse output: %v\n%s", err, out)
+	}
+
+	if obj.Metadata.Name != "team-a-web" || obj.Metadata.Namespace != "prod" {
+		t.Errorf("unexpected identity %s/%s", obj.Metadata.Namespace, obj.Metadata.Name)
+	}
+	if obj.Metadata.Labels["env"] != "prod" || obj.Spec.Selector.MatchLabels["env"] != "prod" {
+		t.Errorf("common labels not applied: %v / %v", obj.Metadata.Labels, obj.Spec.Selector.MatchLabels)
+	}
+	if len(obj.Spec.Template.Spec.Containers) != 1 || obj.Spec.Template.Spec.Containers[0].Image != "nginx:1.27" {
+		t.Errorf("image override not applied: %+v", obj.Spec.Template.Spec.Containers)
+	}
+}
+
+func TestBuildMissingKustomization(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Build(dir, dir, nil); err == nil {
+		t.Fatal("expected error for directory without kustomization")
+	}
+}
+
+func TestBuildRejectsBasesOutsideRepository(t *testing.T) {
+	parent := t.TempDir()
+	outside := filepath.Join(parent, "other-tenant")
+	root := filepath.Join(parent, "repo")
+	for _, dir := range []string{outside, filepath.Join(root, "app")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(outside, "kustomization.yaml"):     "resources:\n- deployment.yaml\n",
+		filepath.Join(outside, "deployment.yaml"):        deployment,
+		filepath.Join(root, "app", "kustomization.yaml"): "resources:\n- ../../other-tenant\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, opts := range []*configsv1alpha1.KustomizeRender{nil, {Namespace: "prod"}} {
+		out, err := Build(root, filepath.Join(root, "app"), opts)
+		if err == nil {
+			t.Fatalf("expected base outside the repository to be rejected, got:\n%s", out)
+		}
+		if !strings.Contains(err.Error(), "outside of the repository") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestBuildRejectsRemoteResources(t *testing.T) {
+	for _, kustomization := range []string{
+		"resources:\n- github.com/example/configs//base?ref=v1.0.0\n",
+		"resources:\n- https://example.com/deployment.yaml\n",
+		"resources:\n- deployment.yaml\nconfigMapGenerator:\n- name: remote\n  files:\n  - config=https://example.com/app.conf\n",
+	} {
+		dir := writeKustomization(t)
+		if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(kustomization), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Build(dir, dir, nil); err == nil {
+			t.Fatalf("expected remote reference to be rejected:\n%s", kustomization)
+		}
+	}
+}
+
+func TestBuildLoadsOnlyReferencedDirectories(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"base/kustomization.yaml":           "resources:\n- deployment.yaml\n",
+		"base/deployment.yaml":              deployment,
+		"overlays/prod/kustomization.yaml":  "resources:\n- ../../base\nnamespace: prod\n",
+		"overlays/dev/kustomization.yaml":   "resources:\n- ../../base\n",
+		"docs/large-unrelated-artifact.bin": "unrelated",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := Build(root, filepath.Join(root, "overlays", "prod"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(out), "namespace: prod") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	var roots []string
+	if err := checkLocal(root, filepath.Join(root, "overlays", "prod"), map[string]bool{}, &roots); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fSys, err := loadRoots(root, roots)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, p := range []string{"/source/overlays/prod/kustomization.yaml", "/source/base/deployment.yaml"} {
+		if !fSys.Exists(p) {
+			t.Errorf("expected %s to be loaded", p)
+		}
+	}
+	for _, p := range []string{"/source/overlays/dev", "/source/docs"} {
+		if fSys.Exists(p) {
+			t.Errorf("expected %s not to be loaded", p)
+		}
+	}
+}
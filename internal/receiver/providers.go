@@ -0,0 +1,192 @@
+package receiver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+var (
+	// errUnauthorized reports a payload whose signature or token does not
+	// match the Receiver's secret.
+	errUnauthorized = errors.New("invalid webhook signature")
+	// errIgnored reports an authenticated event that is not a push, such as
+	// GitHub's ping.
+	errIgnored = errors.New("not a push event")
+)
+
+// pushEvent is the part of a push payload needed to find the ConfigSyncs to
+// reconcile.
+type pushEvent struct {
+	// RepoURLs are the URLs the pushed repository is known by.
+	RepoURLs []string
+	// Refs are the pushed refs, for example `refs/heads/main`.
+	Refs []string
+	// DefaultBranch is the repository's default branch, if the payload
+	// carries it.
+	DefaultBranch string
+}
+
+// parsePush authenticates the request of a provider of the given type with
+// token and extracts the push event from its body.
+func parsePush(provider string, header http.Header, body, token []byte) (*pushEvent, error) {
+	switch provider {
+	case configsv1alpha1.ReceiverTypeGitHub:
+		if !validHMAC(strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256="), body, token) {
+			return nil, errUnauthorized
+		}
+		if header.Get("X-GitHub-Event") != "push" {
+			return nil, errIgnored
+		}
+		return parseGitHubPush(body)
+	case configsv1alpha1.ReceiverTypeGitea:
+		if !validHMAC(header.Get("X-Gitea-Signature"), body, token) {
+			return nil, errUnauthorized
+		}
+		if header.Get("X-Gitea-Event") != "push" {
+			return nil, errIgnored
+		}
+		// Gitea sends GitHub-compatible push payloads
+		return parseGitHubPush(body)
+	case configsv1alpha1.ReceiverTypeGitLab:
+		if subtle.ConstantTimeCompare([]byte(header.Get("X-Gitlab-Token")), token) != 1 {
+			return nil, errUnauthorized
+		}
+		if event := header.Get("X-Gitlab-Event"); event != "Push Hook" && event != "Tag Push Hook" {
+			return nil, errIgnored
+		}
+		return parseGitLabPush(body)
+	case configsv1alpha1.ReceiverTypeBitbucket:
+		if !validHMAC(strings.TrimPrefix(header.Get("X-Hub-Signature"), "sha256="), body, token) {
+			return nil, errUnauthorized
+		}
+		if event := header.Get("X-Event-Key"); event != "repo:push" && event != "repo:refs_changed" {
+			return nil, errIgnored
+		}
+		return parseBitbucketPush(body)
+	}
+	return nil, fmt.Errorf("unsupported receiver type %q", provider)
+}
+
+// validHMAC reports whether signature is the hex-encoded HMAC-SHA256 of body
+// keyed with token.
+func validHMAC(signature string, body, token []byte) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, token)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func parseGitHubPush(body []byte) (*pushEvent, error) {
+	var payload struct {
+		Ref        string `json:"ref"`
+		Repository struct {
+			CloneURL      string `json:"clone_url"`
+			SSHURL        string `json:"ssh_url"`
+			HTMLURL       string `json:"html_url"`
+			DefaultBranch string `json:"default_branch"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse push payload: %w", err)
+	}
+	repo := payload.Repository
+	return &pushEvent{
+		RepoURLs:      nonEmpty(repo.CloneURL, repo.SSHURL, repo.HTMLURL),
+		Refs:          nonEmpty(payload.Ref),
+		DefaultBranch: repo.DefaultBranch,
+	}, nil
+}
+
+func parseGitLabPush(body []byte) (*pushEvent, error) {
+	var payload struct {
+		Ref     string `json:"ref"`
+		Project struct {
+			HTTPURL       string `json:"git_http_url"`
+			SSHURL        string `json:"git_ssh_url"`
+			WebURL        string `json:"web_url"`
+			DefaultBranch string `json:"default_branch"`
+		} `json:"project"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse push payload: %w", err)
+	}
+	project := payload.Project
+	return &pushEvent{
+		RepoURLs:      nonEmpty(project.HTTPURL, project.SSHURL, project.WebURL),
+		Refs:          nonEmpty(payload.Ref),
+		DefaultBranch: project.DefaultBranch,
+	}, nil
+}
+
+// parseBitbucketPush reads both Bitbucket Cloud (`repo:push`) and Bitbucket
+// Data Center (`repo:refs_changed`) payloads.
+func parseBitbucketPush(body []byte) (*pushEvent, error) {
+	type link struct {
+		Href string `json:"href"`
+	}
+	var payload struct {
+		Repository struct {
+			Links struct {
+				HTML  link   `json:"html"`
+				Clone []link `json:"clone"`
+			} `json:"links"`
+		} `json:"repository"`
+		Push struct {
+			Changes []struct {
+				New *struct {
+					Type string `json:"type"`
+					Name string `json:"name"`
+				} `json:"new"`
+			} `json:"changes"`
+		} `json:"push"`
+		Changes []struct {
+			Ref struct {
+				ID string `json:"id"`
+			} `json:"ref"`
+		} `json:"changes"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse push payload: %w", err)
+	}
+
+	event := &pushEvent{RepoURLs: nonEmpty(payload.Repository.Links.HTML.Href)}
+	for _, l := range payload.Repository.Links.Clone {
+		event.RepoURLs = append(event.RepoURLs, nonEmpty(l.Href)...)
+	}
+	for _, change := range payload.Push.Changes {
+		switch {
+		case change.New == nil:
+			// The ref was deleted
+		case change.New.Type == "branch":
+			event.Refs = append(event.Refs, "refs/heads/"+change.New.Name)
+		case change.New.Type == "tag":
+			event.Refs = append(event.Refs, "refs/tags/"+change.New.Name)
+		}
+	}
+	for _, change := range payload.Changes {
+		event.Refs = append(event.Refs, nonEmpty(change.Ref.ID)...)
+	}
+	return event, nil
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
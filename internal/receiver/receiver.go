@@ -0,0 +1,240 @@
+// Package receiver serves the webhook endpoints of Receivers: it
+// authenticates Git push events and triggers a reconcile of the ConfigSyncs
+// that sync the pushed repository and ref.
+package receiver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// PathPrefix is where the Handler is mounted; a Receiver is served at
+// PathPrefix + "<namespace>/<name>".
+const PathPrefix = "/hook/"
+
+// maxPayloadSize bounds the push payloads read by the Handler.
+const maxPayloadSize = 5 << 20
+
+// tokenKey is the key of the Receiver Secret holding the webhook secret.
+const tokenKey = "token"
+
+// +kubebuilder:rbac:groups=configs.example.io,resources=receivers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=configs.example.io,resources=receivers/status,verbs=get;update;patch
+
+// Handler serves the webhook endpoints of all Receivers.
+type Handler struct {
+	Client client.Client
+	// Trigger receives an event for every ConfigSync to reconcile.
+	Trigger chan<- event.GenericEvent
+	// Elected, when set, is closed once this replica leads and runs the
+	// controller consuming Trigger. Until then events are refused, so that
+	// the provider retries against the leader.
+	Elected <-chan struct{}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := ctrl.Log.WithName("receiver").WithValues("path", r.URL.Path)
+	ctx := ctrl.LoggerInto(r.Context(), logger)
+
+	if h.Elected != nil {
+		select {
+		case <-h.Elected:
+		default:
+			http.Error(w, "not the leader", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	namespace, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, PathPrefix), "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	var receiver configsv1alpha1.Receiver
+	if err := h.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &receiver); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.NotFound(w, r)
+			return
+		}
+		logger.Error(err, "failed to read Receiver")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	var secret corev1.Secret
+	if err := h.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: receiver.Spec.SecretRef.Name}, &secret); err != nil {
+		logger.Error(err, "failed to read Receiver Secret", "secret", receiver.Spec.SecretRef.Name)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	token := secret.Data[tokenKey]
+	if len(token) == 0 {
+		logger.Error(fmt.Errorf("secret %s/%s has no %q key", namespace, secret.Name, tokenKey), "invalid Receiver Secret")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize+1))
+	if err != nil {
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxPayloadSize {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	push, err := parsePush(receiver.Spec.Type, r.Header, body, token)
+	switch {
+	case errors.Is(err, errUnauthorized):
+		logger.Info("rejected unauthenticated event")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case errors.Is(err, errIgnored):
+		w.WriteHeader(http.StatusOK)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	triggered, err := h.trigger(ctx, &receiver, push)
+	if err != nil {
+		logger.Error(err, "failed to trigger ConfigSyncs")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("push event received", "refs", push.Refs, "triggered", triggered)
+
+	now := metav1.Now()
+	receiver.Status.LastEventTime = &now
+	receiver.Status.LastEventRef = strings.Join(push.Refs, ",")
+	receiver.Status.Triggered = triggered
+	if err := h.Client.Status().Update(ctx, &receiver); err != nil {
+		// The ConfigSyncs are already triggered; don't make the provider retry
+		logger.Error(err, "failed to update Receiver status")
+	}
+	_, _ = fmt.Fprintf(w, "triggered %d ConfigSyncs\n", len(triggered))
+}
+
+// trigger sends an event for every ConfigSync selected by receiver that
+// syncs the repository and a ref of push, and returns their names.
+func (h *Handler) trigger(ctx context.Context, receiver *configsv1alpha1.Receiver, push *pushEvent) ([]string, error) {
+	selector := labels.Everything()
+	if receiver.Spec.Selector != nil {
+		var err error
+		selector, err = metav1.LabelSelectorAsSelector(receiver.Spec.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector: %w", err)
+		}
+	}
+	var list configsv1alpha1.ConfigSyncList
+	if err := h.Client.List(ctx, &list, client.InNamespace(receiver.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	var triggered []string
+	for i := range list.Items {
+		cs := &list.Items[i]
+		if !matches(cs, push) {
+			continue
+		}
+		select {
+		case h.Trigger <- event.GenericEvent{Object: cs}:
+		case <-ctx.Done():
+			return triggered, ctx.Err()
+		}
+		triggered = append(triggered, cs.Name)
+	}
+	return triggered, nil
+}
+
+// matches reports whether cs syncs the repository of push and would check
+// out a different revision after it.
+func matches(cs *configsv1alpha1.ConfigSync, push *pushEvent) bool {
+	git := cs.Spec.Source.Git
+	if git == nil {
+		return false
+	}
+	repo := normalizeRepoURL(git.RepoURL)
+	sameRepo := false
+	for _, u := range push.RepoURLs {
+		if normalizeRepoURL(u) == repo {
+			sameRepo = true
+			break
+		}
+	}
+	if !sameRepo {
+		return false
+	}
+
+	var ref configsv1alpha1.GitRef
+	if git.Ref != nil {
+		ref = *git.Ref
+	}
+	if ref.Commit != "" || git.Revision != "" {
+		// Pinned to a commit; a push can't change it
+		return false
+	}
+	for _, pushed := range push.Refs {
+		if tag, ok := strings.CutPrefix(pushed, "refs/tags/"); ok {
+			if ref.Semver != "" || ref.Tag == tag {
+				return true
+			}
+			continue
+		}
+		branch, ok := strings.CutPrefix(pushed, "refs/heads/")
+		if !ok || ref.Tag != "" || ref.Semver != "" {
+			continue
+		}
+		switch {
+		case git.Branch != "":
+			if git.Branch == branch {
+				return true
+			}
+		case push.DefaultBranch == "" || push.DefaultBranch == branch:
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeRepoURL reduces the HTTPS, SSH and scp-like forms of a repository
+// URL to `host/path`, so that the URLs of a push payload can be compared
+// with spec.source.git.repoURL.
+func normalizeRepoURL(raw string) string {
+	u := strings.ToLower(strings.TrimSpace(raw))
+	if _, rest, ok := strings.Cut(u, "://"); ok {
+		u = rest
+	} else if host, path, ok := strings.Cut(u, ":"); ok && !strings.Contains(host, "/") {
+		// scp-like syntax: git@host:org/repo.git
+		u = host + "/" + path
+	}
+	host, path, _ := strings.Cut(u, "/")
+	if _, h, ok := strings.Cut(host, "@"); ok {
+		host = h
+	}
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		host = h
+	}
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+	return host + "/" + path
+}
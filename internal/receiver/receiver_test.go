@@ -0,0 +1,250 @@
+package receiver
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+const token = "s3cret"
+
+func sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func gitConfigSync(name, repoURL, branch string, ref *configsv1alpha1.GitRef) *configsv1alpha1.ConfigSync {
+	return &configsv1alpha1.ConfigSync{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"team": name}},
+		Spec: configsv1alpha1.ConfigSyncSpec{Source: configsv1alpha1.SourceSpec{Git: &configsv1alpha1.GitSource{
+			RepoURL: repoURL,
+			Branch:  branch,
+			Ref:     ref,
+		}}},
+	}
+}
+
+// newHandler returns a Handler serving a Receiver named provider for every
+// provider, along with the channel it triggers ConfigSyncs on.
+func newHandler(t *testing.T, objs ...client.Object) (*Handler, chan event.GenericEvent, client.Client) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := configsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	objs = append(objs, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook-token", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte(token)},
+	})
+	for _, provider := range []string{"github", "gitlab", "gitea", "bitbucket"} {
+		objs = append(objs, &configsv1alpha1.Receiver{
+			ObjectMeta: metav1.ObjectMeta{Name: provider, Namespace: "default"},
+			Spec: configsv1alpha1.ReceiverSpec{
+				Type:      provider,
+				SecretRef: configsv1alpha1.LocalObjectReference{Name: "webhook-token"},
+			},
+		})
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithStatusSubresource(&configsv1alpha1.Receiver{}).Build()
+	triggers := make(chan event.GenericEvent, 10)
+	return &Handler{Client: c, Trigger: triggers}, triggers, c
+}
+
+func drain(triggers chan event.GenericEvent) []string {
+	var names []string
+	for {
+		select {
+		case e := <-triggers:
+			names = append(names, e.Object.GetName())
+		default:
+			return names
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	githubPush := []byte(`{"ref":"refs/heads/main","repository":{"clone_url":"https://github.com/org/configs.git",` +
+		`"ssh_url":"git@github.com:org/configs.git","default_branch":"main"}}`)
+	gitlabPush := []byte(`{"ref":"refs/heads/main","project":{"git_http_url":"https://gitlab.com/org/configs.git",` +
+		`"git_ssh_url":"git@gitlab.com:org/configs.git","default_branch":"main"}}`)
+	bitbucketPush := []byte(`{"repository":{"links":{"html":{"href":"https://bitbucket.org/org/configs"}}},` +
+		`"push":{"changes":[{"new":{"type":"branch","name":"main"}}]}}`)
+
+	tests := []struct {
+		name       string
+		receiver   string
+		header     map[string]string
+		body       []byte
+		wantStatus int
+		want       []string
+	}{
+		{
+			name:     "github push",
+			receiver: "github",
+			header:   map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign(githubPush)},
+			body:     githubPush, wantStatus: http.StatusOK, want: []string{"github-main", "github-default", "github-ssh"},
+		},
+		{
+			name:     "github ping",
+			receiver: "github",
+			header:   map[string]string{"X-GitHub-Event": "ping", "X-Hub-Signature-256": "sha256=" + sign([]byte(`{}`))},
+			body:     []byte(`{}`), wantStatus: http.StatusOK,
+		},
+		{
+			name:     "github bad signature",
+			receiver: "github",
+			header:   map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=" + sign([]byte("other"))},
+			body:     githubPush, wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:     "gitea push",
+			receiver: "gitea",
+			header:   map[string]string{"X-Gitea-Event": "push", "X-Gitea-Signature": sign(githubPush)},
+			body:     githubPush, wantStatus: http.StatusOK, want: []string{"github-main", "github-default", "github-ssh"},
+		},
+		{
+			name:     "gitlab push",
+			receiver: "gitlab",
+			header:   map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": token},
+			body:     gitlabPush, wantStatus: http.StatusOK, want: []string{"gitlab"},
+		},
+		{
+			name:     "gitlab wrong token",
+			receiver: "gitlab",
+			header:   map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "guess"},
+			body:     gitlabPush, wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:     "bitbucket push",
+			receiver: "bitbucket",
+			header:   map[string]string{"X-Event-Key": "repo:push", "X-Hub-Signature": "sha256=" + sign(bitbucketPush)},
+			body:     bitbucketPush, wantStatus: http.StatusOK, want: []string{"bitbucket"},
+		},
+		{
+			name:       "unknown receiver",
+			receiver:   "missing",
+			body:       githubPush,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, triggers, c := newHandler(t,
+				gitConfigSync("github-main", "https://github.com/org/configs", "main", nil),
+				gitConfigSync("github-default", "https://github.com/org/configs.git", "", nil),
+				gitConfigSync("github-ssh", "ssh://git@github.com:22/org/configs.git", "main", nil),
+				gitConfigSync("github-other-branch", "https://github.com/org/configs.git", "dev", nil),
+				gitConfigSync("github-tag", "https://github.com/org/configs.git", "", &configsv1alpha1.GitRef{Semver: "~1"}),
+				gitConfigSync("github-other-repo", "https://github.com/org/other.git", "main", nil),
+				gitConfigSync("gitlab", "git@gitlab.com:org/configs.git", "main", nil),
+				gitConfigSync("bitbucket", "https://user@bitbucket.org/org/configs.git", "main", nil),
+			)
+			req := httptest.NewRequest(http.MethodPost, PathPrefix+"default/"+tt.receiver, bytes.NewReader(tt.body))
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body)
+			}
+			got := drain(triggers)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v to be triggered, got %v", tt.want, got)
+			}
+			for i := range got {
+				if !slices.Contains(tt.want, got[i]) {
+					t.Fatalf("expected %v to be triggered, got %v", tt.want, got)
+				}
+			}
+
+			if len(tt.want) > 0 {
+				var receiver configsv1alpha1.Receiver
+				if err := c.Get(req.Context(), client.ObjectKey{Namespace: "default", Name: tt.receiver}, &receiver); err != nil {
+					t.Fatal(err)
+				}
+				if receiver.Status.LastEventTime == nil || len(receiver.Status.Triggered) != len(tt.want) {
+					t.Fatalf("expected the event to be recorded in status, got %+v", receiver.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerSelectorAndLeadership(t *testing.T) {
+	body := []byte(`{"ref":"refs/tags/v1.2.0","repository":{"clone_url":"https://github.com/org/configs.git"}}`)
+	h, triggers, c := newHandler(t,
+		gitConfigSync("tagged", "https://github.com/org/configs.git", "", &configsv1alpha1.GitRef{Semver: "~1"}),
+		gitConfigSync("pinned", "https://github.com/org/configs.git", "", &configsv1alpha1.GitRef{Tag: "v1.0.0"}),
+		gitConfigSync("other-team", "https://github.com/org/configs.git", "", &configsv1alpha1.GitRef{Semver: "*"}),
+	)
+	var receiver configsv1alpha1.Receiver
+	if err := c.Get(t.Context(), client.ObjectKey{Namespace: "default", Name: "github"}, &receiver); err != nil {
+		t.Fatal(err)
+	}
+	receiver.Spec.Selector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "team", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"other-team"}},
+	}}
+	if err := c.Update(t.Context(), &receiver); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, PathPrefix+"default/github", bytes.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", "sha256="+sign(body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	elected := make(chan struct{})
+	h.Elected = elected
+	if rec := post(); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected a replica that does not lead to refuse events, got %d", rec.Code)
+	}
+	close(elected)
+	if rec := post(); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if got := drain(triggers); len(got) != 1 || got[0] != "tagged" {
+		t.Fatalf("expected only the selected semver ConfigSync to be triggered by a tag push, got %v", got)
+	}
+}
+
+func TestNormalizeRepoURL(t *testing.T) {
+	want := "github.com/org/configs"
+	for _, u := range []string{
+		"https://github.com/org/configs",
+		"https://GitHub.com/org/configs.git/",
+		"https://token@github.com/org/configs.git",
+		"git@github.com:org/configs.git",
+		"ssh://git@github.com:22/org/configs.git",
+	} {
+		if got := normalizeRepoURL(u); got != want {
+			t.Errorf("normalizeRepoURL(%q) = %q, want %q", u, got, want)
+		}
+	}
+}
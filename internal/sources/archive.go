@@ -0,0 +1,163 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// maxExtractedSize bounds the total size of the files extracted from an
+// archive, so that a small but highly compressed archive cannot fill the disk.
+const maxExtractedSize = 1 << 30
+
+// sizeBudget tracks how many more bytes may be extracted.
+type sizeBudget struct {
+	limit     int64
+	remaining int64
+}
+
+func newSizeBudget(limit int64) *sizeBudget {
+	return &sizeBudget{limit: limit, remaining: limit}
+}
+
+// extractArchive unpacks data, a gzipped tarball, a tarball or a zip
+// archive, into dir. The format is detected from the content. The extracted
+// files may not exceed maxExtractedSize in total.
+func extractArchive(data []byte, dir string) error {
+	budget := newSizeBudget(maxExtractedSize)
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		return extractTar(gz, dir, budget)
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		return extractZip(data, dir, budget)
+	case len(data) > 262 && string(data[257:262]) == "ustar":
+		return extractTar(bytes.NewReader(data), dir, budget)
+	}
+	return fmt.Errorf("unsupported archive format; expected a tarball or zip archive")
+}
+
+// extractTar unpacks the regular files of the tarball read from r into dir,
+// charging them to budget. Directories are created as needed; links and
+// special files are skipped.
+func extractTar(r io.Reader, dir string, budget *sizeBudget) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := writeExtracted(dir, hdr.Name, tr, filePerm(hdr.FileInfo().Mode()), budget); err != nil {
+			return err
+		}
+	}
+}
+
+// extractZip unpacks the regular files of the zip archive data into dir,
+// charging them to budget.
+func extractZip(data []byte, dir string, budget *sizeBudget) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeExtracted(dir, f.Name, rc, filePerm(f.Mode()), budget)
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filePerm keeps only the executable bit of an archived file's mode.
+func filePerm(mode os.FileMode) os.FileMode {
+	if mode&0o111 != 0 {
+		return 0o755
+	}
+	return 0o644
+}
+
+// writeExtracted writes the file name, which must stay within dir. Its size
+// is charged to budget, failing once the budget is exhausted; a nil budget
+// sets no limit.
+func writeExtracted(dir, name string, r io.Reader, perm os.FileMode, budget *sizeBudget) error {
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if !filepath.IsLocal(rel) {
+		return fmt.Errorf("path %q escapes the artifact root", name)
+	}
+	target := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if budget != nil {
+		r = io.LimitReader(r, budget.remaining+1)
+	}
+	n, err := io.Copy(out, r)
+	if err != nil {
+		_ = out.Close()
+		return err
+	}
+	if budget != nil {
+		if n > budget.remaining {
+			_ = out.Close()
+			return fmt.Errorf("extracted content exceeds %d bytes", budget.limit)
+		}
+		budget.remaining -= n
+	}
+	return out.Close()
+}
+
+// contentDigest hashes the paths and contents of the files under dir, so
+// that the digest only changes when the extracted content does.
+func contentDigest(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		_, _ = fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(rel), hex.EncodeToString(sum[:]))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,142 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarGz returns files packaged as a gzipped tarball.
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// zipArchive returns files packaged as a zip archive.
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractArchive(t *testing.T) {
+	files := map[string]string{"deploy/cm.yaml": "cm", "deploy/nested/secret.yaml": "secret"}
+	var digests []string
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{name: "tar.gz", data: tarGz(t, files)},
+		{name: "zip", data: zipArchive(t, files)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := extractArchive(tc.data, dir); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for name, want := range files {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil || string(got) != want {
+					t.Fatalf("expected %s to hold %q, got %q (%v)", name, want, got, err)
+				}
+			}
+			digest, err := contentDigest(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			digests = append(digests, digest)
+		})
+	}
+	if len(digests) != 2 || digests[0] != digests[1] {
+		t.Fatalf("expected the same content digest for both formats, got %v", digests)
+	}
+
+	if err := extractArchive([]byte("kind: ConfigMap"), t.TempDir()); err == nil || !strings.Contains(err.Error(), "unsupported archive format") {
+		t.Fatalf("expected a plain file to be rejected, got %v", err)
+	}
+}
+
+func TestExtractTarRejectsEscapingPaths(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "../evil.yaml", Mode: 0o644, Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := extractTar(&buf, t.TempDir(), newSizeBudget(maxExtractedSize))
+	if err == nil || !strings.Contains(err.Error(), "escapes the artifact root") {
+		t.Fatalf("expected an escaping path to be rejected, got %v", err)
+	}
+}
+
+func TestExtractTarLimitsExtractedSize(t *testing.T) {
+	zeros := strings.Repeat("\x00", 600<<10)
+	for name, files := range map[string]map[string]string{
+		"single file": {"bomb.yaml": strings.Repeat(zeros, 4)},
+		"total size":  {"a.yaml": zeros, "b.yaml": zeros},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := tarGz(t, files)
+			if len(data) > 64<<10 {
+				t.Fatalf("expected a highly compressed archive, got %d bytes", len(data))
+			}
+			gz, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = extractTar(gz, t.TempDir(), newSizeBudget(1<<20))
+			if err == nil || !strings.Contains(err.Error(), "exceeds 1048576 bytes") {
+				t.Fatalf("expected the extracted size to be limited, got %v", err)
+			}
+		})
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(tarGz(t, map[string]string{"a.yaml": zeros})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := extractTar(gz, t.TempDir(), newSizeBudget(1<<20)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
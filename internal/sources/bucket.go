@@ -0,0 +1,317 @@
+package source
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+const (
+	// defaultBucketRegion signs requests when spec.source.bucket.region is unset.
+	defaultBucketRegion = "us-east-1"
+	// emptyPayloadHash is the SHA-256 of an empty request body.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// s3Credential signs requests to an S3-compatible service.
+type s3Credential struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// s3Client issues signed, path-style requests to a bucket.
+type s3Client struct {
+	baseURL string
+	bucket  string
+	region  string
+	// cred is nil for anonymous requests.
+	cred *s3Credential
+	now  func() time.Time
+}
+
+// fetchBucket downloads the objects under spec.Prefix into a new temporary
+// directory, which the caller must remove. The digest of the downloaded
+// content becomes the result's revision.
+func fetchBucket(ctx context.Context, c client.Client, namespace string, spec *configsv1alpha1.BucketSource) (*Result, error) {
+	logger := log.FromContext(ctx)
+
+	s3 := &s3Client{
+		baseURL: "https://" + spec.Endpoint,
+		bucket:  spec.Bucket,
+		region:  spec.Region,
+		now:     time.Now,
+	}
+	if spec.Insecure {
+		s3.baseURL = "http://" + spec.Endpoint
+	}
+	if s3.region == "" {
+		s3.region = defaultBucketRegion
+	}
+	if spec.SecretRef != nil {
+		cred, err := bucketCredential(ctx, c, namespace, spec.SecretRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		s3.cred = cred
+	}
+
+	objects, err := s3.list(ctx, spec.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bucket %s: %w", spec.Bucket, err)
+	}
+	var total int64
+	for _, obj := range objects {
+		total += obj.Size
+	}
+	if total > maxDownloadSize {
+		return nil, fmt.Errorf("objects under %q exceed %d bytes", spec.Prefix, maxDownloadSize)
+	}
+
+	dir, err := os.MkdirTemp("", "config-sync-bucket-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download directory: %w", err)
+	}
+	// The listed sizes are only advisory; the downloads are charged against
+	// the same limit as they are written
+	budget := newSizeBudget(maxDownloadSize)
+	for _, obj := range objects {
+		rel := strings.TrimPrefix(obj.Key, spec.Prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			continue
+		}
+		if err := s3.download(ctx, obj.Key, dir, rel, budget); err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to download %s: %w", obj.Key, err)
+		}
+	}
+	revision, err := contentDigest(dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	logger.Info("bucket downloaded", "bucket", spec.Bucket, "prefix", spec.Prefix, "objects", len(objects), "digest", revision)
+	return &Result{Revision: revision, Path: dir}, nil
+}
+
+// bucketCredential reads the access keys from the Secret name in namespace.
+func bucketCredential(ctx context.Context, c client.Client, namespace, name string) (*s3Credential, error) {
+	var secret corev1.Secret
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	if err := c.Get(ctx, key, &secret); err != nil {
+		return nil, fmt.Errorf("failed to read Secret %s: %w", key, err)
+	}
+	cred := &s3Credential{
+		AccessKeyID:     string(secret.Data["accessKeyID"]),
+		SecretAccessKey: string(secret.Data["secretAccessKey"]),
+		SessionToken:    string(secret.Data["sessionToken"]),
+	}
+	if cred.AccessKeyID == "" || cred.SecretAccessKey == "" {
+		return nil, fmt.Errorf("secret %s missing accessKeyID/secretAccessKey for bucket auth", key)
+	}
+	return cred, nil
+}
+
+// s3Object is an entry of a ListObjectsV2 response.
+type s3Object struct {
+	Key  string `xml:"Key"`
+	Size int64  `xml:"Size"`
+}
+
+// listBucketResult is the body of a ListObjectsV2 response.
+type listBucketResult struct {
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+}
+
+// s3Error is the body of a failed request.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// list returns every object whose key starts with prefix.
+func (s *s3Client) list(ctx context.Context, prefix string) ([]s3Object, error) {
+	var objects []s3Object
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		resp, err := s.get(ctx, "", query)
+		if err != nil {
+			return nil, err
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse object listing: %w", err)
+		}
+		objects = append(objects, page.Contents...)
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		query.Set("continuation-token", page.NextContinuationToken)
+	}
+}
+
+// download writes the object key to the file rel under dir, charging it to
+// budget.
+func (s *s3Client) download(ctx context.Context, key, dir, rel string, budget *sizeBudget) error {
+	resp, err := s.get(ctx, key, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return writeExtracted(dir, rel, resp.Body, 0o644, budget)
+}
+
+// get sends a signed GET request for key (the bucket itself when empty) and
+// returns the response if it succeeded.
+func (s *s3Client) get(ctx context.Context, key string, query url.Values) (*http.Response, error) {
+	u, err := url.Parse(s.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", s.baseURL, err)
+	}
+	u.Path = "/" + s.bucket + "/" + key
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.cred != nil {
+		signV4(req, *s.cred, s.region, s.now())
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var e s3Error
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e); err == nil && e.Code != "" {
+		return nil, fmt.Errorf("%s %s: %s: %s", req.Method, u.Path, e.Code, e.Message)
+	}
+	return nil, fmt.Errorf("%s %s: unexpected status %s", req.Method, u.Path, resp.Status)
+}
+
+// signV4 signs req with AWS Signature Version 4 for the s3 service. Every
+// header already set on req is signed along with the host.
+func signV4(req *http.Request, cred s3Credential, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if req.Header.Get("X-Amz-Content-Sha256") == "" {
+		req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	}
+	if cred.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", cred.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		for i := range values {
+			values[i] = strings.Join(strings.Fields(values[i]), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscape(path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + cred.SecretAccessKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cred.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by key and value, as SigV4 expects.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes every byte of s except unreserved characters
+// and, unless encodeSlash is set, `/`.
+func awsEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,584 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// reposDir holds one bare repository per repository URL.
+	reposDir = "repos"
+	// checkoutsDir holds the checked-out commits of each repository.
+	checkoutsDir = "checkouts"
+
+	kindRepository = "repository"
+	kindCheckout   = "checkout"
+
+	// sweepInterval is how often Start evicts stale entries.
+	sweepInterval = time.Minute
+	// evictionGrace protects recently used entries from size-based eviction
+	// while a reconcile may still be reading them.
+	evictionGrace = time.Minute
+)
+
+// DefaultCacheRoot is the cache directory used when none is configured.
+func DefaultCacheRoot() string {
+	return filepath.Join(os.TempDir(), "config-sync-cache")
+}
+
+// CacheOptions configure a Cache.
+type CacheOptions struct {
+	// Root is the directory holding the cache.
+	Root string
+	// TTL evicts entries that were not used for this long. Zero disables
+	// TTL eviction.
+	TTL time.Duration
+	// MaxSize evicts the least recently used entries while the cache takes
+	// more bytes on disk. Zero disables size-based eviction.
+	MaxSize int64
+}
+
+// Cache stores fetched Git repositories on disk, shared by all ConfigSyncs.
+//
+// Every repository URL gets a single bare repository under <root>/repos, and
+// every synced commit is written out as a plain directory under
+// <root>/checkouts. ConfigSyncs following different refs of one repository
+// therefore never share a worktree, while those following the same ref share
+// the checkout. Fetches and checkouts of a repository are serialized by a
+// per-repository lock.
+//
+// Entries not used within the TTL are evicted by Start, as are the least
+// recently used entries while the cache exceeds its maximum size. Checkouts
+// replaced by a newer commit of the same ref are evicted at the next sweep.
+type Cache struct {
+	root    string
+	ttl     time.Duration
+	maxSize int64
+	now     func() time.Time
+
+	mu      sync.Mutex
+	locks   map[string]*sync.Mutex
+	entries map[string]*cacheEntry
+	// current maps each synced ref to the checkout it resolved to last.
+	current map[string]string
+	// histories memoizes historySize per repository and commit.
+	histories map[string]int64
+}
+
+// cacheEntry is a repository or a checkout, keyed by its path relative to
+// the cache root.
+type cacheEntry struct {
+	kind       string
+	repoKey    string
+	size       int64
+	lastUsed   time.Time
+	superseded bool
+}
+
+var (
+	defaultCacheOnce sync.Once
+	defaultCache     *Cache
+	defaultCacheErr  error
+)
+
+// NewCache returns a cache rooted at opts.Root, picking up the entries left
+// there by earlier runs.
+func NewCache(opts CacheOptions) (*Cache, error) {
+	c := &Cache{
+		root:      opts.Root,
+		ttl:       opts.TTL,
+		maxSize:   opts.MaxSize,
+		now:       time.Now,
+		locks:     map[string]*sync.Mutex{},
+		entries:   map[string]*cacheEntry{},
+		current:   map[string]string{},
+		histories: map[string]int64{},
+	}
+	for _, dir := range []string{reposDir, checkoutsDir} {
+		if err := os.MkdirAll(filepath.Join(c.root, dir), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+	if err := c.scan(); err != nil {
+		return nil, fmt.Errorf("failed to scan cache directory: %w", err)
+	}
+	return c, nil
+}
+
+// scan registers the repositories and checkouts found on disk, using their
+// modification time as last use. Unfinished checkouts are removed.
+func (c *Cache) scan() error {
+	repos, err := os.ReadDir(filepath.Join(c.root, reposDir))
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		if err := c.scanEntry(filepath.Join(reposDir, repo.Name()), kindRepository, repo.Name()); err != nil {
+			return err
+		}
+	}
+
+	repos, err = os.ReadDir(filepath.Join(c.root, checkoutsDir))
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		checkouts, err := os.ReadDir(filepath.Join(c.root, checkoutsDir, repo.Name()))
+		if err != nil {
+			return err
+		}
+		for _, checkout := range checkouts {
+			rel := filepath.Join(checkoutsDir, repo.Name(), checkout.Name())
+			if strings.HasPrefix(checkout.Name(), ".") {
+				_ = os.RemoveAll(filepath.Join(c.root, rel))
+				continue
+			}
+			if err := c.scanEntry(rel, kindCheckout, repo.Name()); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updateMetrics()
+	return nil
+}
+
+func (c *Cache) scanEntry(rel, kind, repoKey string) error {
+	dir := filepath.Join(c.root, rel)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	size, err := dirSize(dir)
+	if err != nil {
+		return err
+	}
+	c.entries[rel] = &cacheEntry{kind: kind, repoKey: repoKey, size: size, lastUsed: info.ModTime()}
+	return nil
+}
+
+// cache returns the cache configured in o, or the default cache.
+func (o Options) cache() (*Cache, error) {
+	if o.Cache != nil {
+		return o.Cache, nil
+	}
+	defaultCacheOnce.Do(func() {
+		defaultCache, defaultCacheErr = NewCache(CacheOptions{Root: DefaultCacheRoot()})
+	})
+	return defaultCache, defaultCacheErr
+}
+
+// RemoveCache deletes everything cached for the repository at repoURL.
+func (o Options) RemoveCache(repoURL string) error {
+	c, err := o.cache()
+	if err != nil {
+		return err
+	}
+	return c.Remove(repoURL)
+}
+
+// repoKeyFor returns the cache key of the repository at repoURL cloned with
+// the given depth. Shallow clones are kept apart from full ones because a shallow repository
+// cannot be deepened in place.
+func repoKeyFor(repoURL string, depth int) string {
+	key := sanitizeRepoURL(repoURL)
+	if depth > 0 {
+		key += fmt.Sprintf("@depth%d", depth)
+	}
+	return key
+}
+
+// lock acquires the lock of the repository at repoKey and returns its
+// release function.
+func (c *Cache) lock(repoKey string) func() {
+	c.mu.Lock()
+	l := c.repoLock(repoKey)
+	c.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// repoLock returns the lock of the repository at repoKey. c.mu must be held.
+func (c *Cache) repoLock(repoKey string) *sync.Mutex {
+	l, ok := c.locks[repoKey]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[repoKey] = l
+	}
+	return l
+}
+
+func (c *Cache) repoDir(repoKey string) string {
+	return filepath.Join(c.root, reposDir, repoKey)
+}
+
+// openRepository opens the bare repository at repoKey, creating it with an
+// `origin` remote pointing at repoURL when it does not exist yet or is
+// unusable. The caller must hold the repository lock.
+func (c *Cache) openRepository(repoKey, repoURL string) (repo *git.Repository, created bool, err error) {
+	dir := c.repoDir(repoKey)
+	if repo, err := git.PlainOpen(dir); err == nil {
+		// Distinct URLs may sanitize to the same key
+		if remote, err := repo.Remote("origin"); err == nil && remote.Config().URLs[0] == repoURL {
+			return repo, false, nil
+		}
+	}
+
+	if err := c.remove(filepath.Join(reposDir, repoKey)); err != nil {
+		return nil, false, err
+	}
+	repo, err = git.PlainInit(dir, true)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create cached repository: %w", err)
+	}
+	_, err = repo.CreateRemote(&gitconfig.RemoteConfig{Name: "origin", URLs: []string{repoURL}})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to configure cached repository: %w", err)
+	}
+	return repo, true, nil
+}
+
+// historySize returns the size of the history of commit in the repository at
+// repoKey, computing it only once per commit. The caller must hold the
+// repository lock.
+func (c *Cache) historySize(repoKey string, repo *git.Repository, commit *object.Commit) (int64, error) {
+	key := repoKey + "/" + commit.Hash.String()
+	c.mu.Lock()
+	size, ok := c.histories[key]
+	c.mu.Unlock()
+	if ok {
+		return size, nil
+	}
+
+	size, err := historySize(repo, commit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure history of %s: %w", commit.Hash, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.histories[key] = size
+	return size, nil
+}
+
+// recordRepository marks the repository at repoKey as used and returns its
+// size on disk. The caller must hold the repository lock.
+func (c *Cache) recordRepository(repoKey string) (int64, error) {
+	size, err := dirSize(c.repoDir(repoKey))
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure repository size: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[filepath.Join(reposDir, repoKey)] = &cacheEntry{
+		kind:     kindRepository,
+		repoKey:  repoKey,
+		size:     size,
+		lastUsed: c.now(),
+	}
+	c.updateMetrics()
+	return size, nil
+}
+
+// checkout returns the directory holding the tree of commit, limited to
+// sparseDirs when set, and its size. The tree is written out unless an
+// earlier sync already did. ref identifies what resolved to commit, so that
+// the checkout it replaces can be evicted. The caller must hold the
+// repository lock.
+func (c *Cache) checkout(repoKey, ref string, commit *object.Commit, sparseDirs []string) (string, int64, error) {
+	name := commit.Hash.String()
+	if len(sparseDirs) > 0 {
+		sum := sha256.Sum256([]byte(strings.Join(sparseDirs, "\n")))
+		name += "-" + hex.EncodeToString(sum[:4])
+	}
+	rel := filepath.Join(checkoutsDir, repoKey, name)
+	dir := filepath.Join(c.root, rel)
+
+	c.mu.Lock()
+	entry := c.entries[rel]
+	c.mu.Unlock()
+
+	var size int64
+	if _, err := os.Stat(dir); err == nil && entry != nil {
+		size = entry.size
+	} else {
+		_ = os.RemoveAll(dir)
+		if size, err = writeCheckout(commit, dir, sparseDirs); err != nil {
+			return "", 0, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[rel] = &cacheEntry{kind: kindCheckout, repoKey: repoKey, size: size, lastUsed: c.now()}
+
+	refKey := repoKey + "/" + ref
+	prev, ok := c.current[refKey]
+	c.current[refKey] = rel
+	if ok && prev != rel && !c.isCurrent(prev) {
+		if e := c.entries[prev]; e != nil {
+			e.superseded = true
+		}
+	}
+	c.updateMetrics()
+	return dir, size, nil
+}
+
+// isCurrent reports whether any ref still resolves to the checkout at rel.
+// c.mu must be held.
+func (c *Cache) isCurrent(rel string) bool {
+	for _, cur := range c.current {
+		if cur == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCheckout writes the files of commit that lie within sparseDirs, or
+// all of them when it is empty, to dir and returns their total size. The
+// files are written to a temporary directory first, so dir either holds the
+// complete tree or does not exist.
+func writeCheckout(commit *object.Commit, dir string, sparseDirs []string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create checkout directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+"-")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create checkout directory: %w", err)
+	}
+
+	size, err := writeFiles(commit, tmp, sparseDirs)
+	if err == nil {
+		err = os.Rename(tmp, dir)
+	}
+	if err != nil {
+		_ = os.RemoveAll(tmp)
+		return 0, fmt.Errorf("failed to check out %s: %w", commit.Hash, err)
+	}
+	return size, nil
+}
+
+func writeFiles(commit *object.Commit, dir string, sparseDirs []string) (int64, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !inSparseDirs(f.Name, sparseDirs) {
+			return nil
+		}
+		name := filepath.FromSlash(f.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid path %q", f.Name)
+		}
+		target := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+
+		if f.Mode == filemode.Symlink {
+			link, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+
+		perm := os.FileMode(0o644)
+		if f.Mode == filemode.Executable {
+			perm = 0o755
+		}
+		r, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = r.Close() }()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(out, r)
+		if err != nil {
+			_ = out.Close()
+			return err
+		}
+		size += n
+		return out.Close()
+	})
+	return size, err
+}
+
+// inSparseDirs reports whether the repository path name lies within one of
+// dirs, or whether dirs is empty.
+func inSparseDirs(name string, dirs []string) bool {
+	if len(dirs) == 0 {
+		return true
+	}
+	for _, dir := range dirs {
+		if name == dir || strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove deletes the repositories and checkouts cached for repoURL.
+func (c *Cache) Remove(repoURL string) error {
+	base := sanitizeRepoURL(repoURL)
+	for _, parent := range []string{reposDir, checkoutsDir} {
+		keys, err := os.ReadDir(filepath.Join(c.root, parent))
+		if err != nil {
+			return fmt.Errorf("failed to remove cached repository: %w", err)
+		}
+		for _, key := range keys {
+			if key.Name() != base && !strings.HasPrefix(key.Name(), base+"@") {
+				continue
+			}
+			unlock := c.lock(key.Name())
+			err := c.remove(filepath.Join(parent, key.Name()))
+			unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// remove deletes the cache directory at rel along with the entries within.
+// The caller must hold the lock of the repository it belongs to.
+func (c *Cache) remove(rel string) error {
+	if err := os.RemoveAll(filepath.Join(c.root, rel)); err != nil {
+		return fmt.Errorf("failed to remove cached repository: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key == rel || strings.HasPrefix(key, rel+string(filepath.Separator)) {
+			delete(c.entries, key)
+		}
+	}
+	if repoKey, ok := strings.CutPrefix(rel, reposDir+string(filepath.Separator)); ok {
+		for key := range c.histories {
+			if strings.HasPrefix(key, repoKey+"/") {
+				delete(c.histories, key)
+			}
+		}
+	}
+	c.updateMetrics()
+	return nil
+}
+
+// Evict removes entries that outlived the TTL and checkouts replaced by a
+// newer commit, then the least recently used entries until the cache fits
+// within its maximum size. Entries whose repository is locked are skipped.
+func (c *Cache) Evict(ctx context.Context) {
+	logger := log.FromContext(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var total int64
+	byAge := make([]string, 0, len(c.entries))
+	for rel, e := range c.entries {
+		total += e.size
+		byAge = append(byAge, rel)
+	}
+	sort.Slice(byAge, func(i, j int) bool {
+		return c.entries[byAge[i]].lastUsed.Before(c.entries[byAge[j]].lastUsed)
+	})
+
+	for _, rel := range byAge {
+		e := c.entries[rel]
+		idle := now.Sub(e.lastUsed)
+
+		var reason string
+		switch {
+		case c.ttl > 0 && idle > c.ttl:
+			reason = "ttl"
+		case e.superseded && idle > evictionGrace:
+			reason = "superseded"
+		case c.maxSize > 0 && total > c.maxSize && idle > evictionGrace:
+			reason = "size"
+		default:
+			continue
+		}
+
+		l := c.repoLock(e.repoKey)
+		if !l.TryLock() {
+			continue
+		}
+		err := os.RemoveAll(filepath.Join(c.root, rel))
+		if e.kind == kindCheckout {
+			// drop the repository's checkout directory once it is empty
+			_ = os.Remove(filepath.Dir(filepath.Join(c.root, rel)))
+		}
+		l.Unlock()
+		if err != nil {
+			logger.Error(err, "failed to evict cache entry", "path", rel)
+			continue
+		}
+
+		logger.Info("evicted cache entry", "path", rel, "reason", reason, "size", e.size)
+		delete(c.entries, rel)
+		total -= e.size
+		cacheEvictions.WithLabelValues(reason).Inc()
+	}
+	c.updateMetrics()
+}
+
+// Start evicts stale entries periodically until ctx is done.
+func (c *Cache) Start(ctx context.Context) error {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.Evict(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection reports false: every replica manages its own cache.
+func (c *Cache) NeedLeaderElection() bool {
+	return false
+}
+
+// updateMetrics publishes the size of the cache. c.mu must be held.
+func (c *Cache) updateMetrics() {
+	sizes := map[string]int64{kindRepository: 0, kindCheckout: 0}
+	counts := map[string]int{kindRepository: 0, kindCheckout: 0}
+	for _, e := range c.entries {
+		sizes[e.kind] += e.size
+		counts[e.kind]++
+	}
+	for kind := range sizes {
+		cacheSizeBytes.WithLabelValues(kind).Set(float64(sizes[kind]))
+		cacheEntries.WithLabelValues(kind).Set(float64(counts[kind]))
+	}
+}
@@ -0,0 +1,185 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// newBranchedRepo creates a repository whose main and other branches hold
+// different versions of deploy/cm.yaml, and returns its directory.
+func newBranchedRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"deploy/cm.yaml": "main"})
+	runGit(t, dir, "init", "-q", "-b", "main")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "main")
+	runGit(t, dir, "checkout", "-q", "-b", "other")
+	writeTree(t, dir, map[string]string{"deploy/cm.yaml": "other"})
+	runGit(t, dir, "commit", "-q", "-am", "other")
+	runGit(t, dir, "checkout", "-q", "main")
+	return dir
+}
+
+func readCheckout(t *testing.T, result *Result) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(result.Path, "deploy", "cm.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCloneOrUpdateConcurrentRefs(t *testing.T) {
+	repoURL := newBranchedRepo(t)
+	opts := newTestCache(t)
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+
+	branches := []string{"main", "other", ""}
+	results := make([]*Result, 3*len(branches))
+	errs := make([]error, len(results))
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			spec := &configsv1alpha1.GitSource{RepoURL: repoURL, Path: "deploy", AuthMethod: "none", Branch: branches[i%len(branches)]}
+			results[i], errs[i] = cloneOrUpdate(context.Background(), c, "default", spec, opts)
+		}()
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if errs[i] != nil {
+			t.Fatalf("unexpected error: %v", errs[i])
+		}
+		want := branches[i%len(branches)]
+		if want == "" {
+			want = "main"
+		}
+		if got := readCheckout(t, result); got != want {
+			t.Fatalf("expected the %s branch to be checked out, got %q", want, got)
+		}
+	}
+
+	repos, err := os.ReadDir(filepath.Join(opts.Cache.root, reposDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 {
+		t.Fatalf("expected a single cached repository, got %d", len(repos))
+	}
+}
+
+func TestCloneOrUpdateFollowsDefaultBranch(t *testing.T) {
+	repoURL := newBranchedRepo(t)
+	opts := newTestCache(t)
+	now := time.Now()
+	opts.Cache.now = func() time.Time { return now }
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	spec := &configsv1alpha1.GitSource{RepoURL: repoURL, Path: "deploy", AuthMethod: "none"}
+
+	first, err := cloneOrUpdate(context.Background(), c, "default", spec, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	writeTree(t, repoURL, map[string]string{"deploy/cm.yaml": "updated"})
+	runGit(t, repoURL, "commit", "-q", "-am", "update")
+
+	second, err := cloneOrUpdate(context.Background(), c, "default", spec, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.Revision == first.Revision || readCheckout(t, second) != "updated" {
+		t.Fatalf("expected the new commit on the default branch to be checked out, got %s", second.Revision)
+	}
+
+	// The replaced checkout is evicted once no reconcile can still read it
+	opts.Cache.Evict(context.Background())
+	if _, err := os.Stat(first.Path); err != nil {
+		t.Fatalf("expected the replaced checkout to be kept during the grace period: %v", err)
+	}
+	now = now.Add(2 * evictionGrace)
+	opts.Cache.Evict(context.Background())
+	if _, err := os.Stat(first.Path); !os.IsNotExist(err) {
+		t.Fatalf("expected the replaced checkout to be evicted, got %v", err)
+	}
+	if _, err := os.Stat(second.Path); err != nil {
+		t.Fatalf("expected the current checkout to be kept: %v", err)
+	}
+}
+
+func TestCacheEvict(t *testing.T) {
+	root := t.TempDir()
+	cache, err := NewCache(CacheOptions{Root: root, TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	opts := Options{Cache: cache}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+
+	older := &configsv1alpha1.GitSource{RepoURL: newBranchedRepo(t), Path: "deploy", AuthMethod: "none"}
+	if _, err := cloneOrUpdate(context.Background(), c, "default", older, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now = now.Add(10 * time.Minute)
+	newer := &configsv1alpha1.GitSource{RepoURL: newBranchedRepo(t), Path: "deploy", AuthMethod: "none"}
+	result, err := cloneOrUpdate(context.Background(), c, "default", newer, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := testutil.ToFloat64(cacheEntries.WithLabelValues(kindRepository)); got != 2 {
+		t.Fatalf("expected 2 cached repositories, got %v", got)
+	}
+
+	// A restarted cache picks up the entries and drops unfinished checkouts
+	leftover := filepath.Join(root, checkoutsDir, repoKeyFor(newer.RepoURL, 0), ".unfinished")
+	if err := os.Mkdir(leftover, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := NewCache(CacheOptions{Root: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restarted.entries) != len(cache.entries) {
+		t.Fatalf("expected %d entries after a restart, got %d", len(cache.entries), len(restarted.entries))
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Fatalf("expected the unfinished checkout to be removed, got %v", err)
+	}
+
+	// Shrinking the cache evicts the least recently used repository first
+	now = now.Add(2 * evictionGrace)
+	cache.maxSize = cache.entries[filepath.Join(reposDir, repoKeyFor(newer.RepoURL, 0))].size +
+		cache.entries[filepath.Join(checkoutsDir, repoKeyFor(newer.RepoURL, 0), result.Revision)].size
+	cache.Evict(context.Background())
+	if _, err := os.Stat(cache.repoDir(repoKeyFor(older.RepoURL, 0))); !os.IsNotExist(err) {
+		t.Fatalf("expected the least recently used repository to be evicted, got %v", err)
+	}
+	if _, err := os.Stat(result.Path); err != nil {
+		t.Fatalf("expected the most recently used checkout to be kept: %v", err)
+	}
+
+	// Everything expires after the TTL
+	now = now.Add(2 * time.Hour)
+	cache.Evict(context.Background())
+	if len(cache.entries) != 0 {
+		t.Fatalf("expected all entries to expire, got %d", len(cache.entries))
+	}
+	if got := testutil.ToFloat64(cacheSizeBytes.WithLabelValues(kindRepository)); got != 0 {
+		t.Fatalf("expected the cache size metric to drop to 0, got %v", got)
+	}
+}
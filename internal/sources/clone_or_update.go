@@ -2,14 +2,16 @@ package source
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
 	"golang.org/x/crypto/ssh"
 
 	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	gittransport "github.com/go-git/go-git/v5/plumbing/transport"
 	httpAuth "github.com/go-git/go-git/v5/plumbing/transport/http"
@@ -25,137 +27,223 @@ import (
 func cloneOrUpdate(
 	ctx context.Context,
 	c client.Client,
-	repoURL, revision, branch, authMethod string,
-	authSecretRef *configsv1alpha1.ObjectRef,
-) (string, string, string, error) {
+	namespace string,
+	spec *configsv1alpha1.GitSource,
+	opts Options,
+) (*Result, error) {
 
 	logger := log.FromContext(ctx)
 
-	cacheBase := filepath.Join(os.TempDir(), "config-sync-cache")
-	repoName := sanitizeRepoURL(repoURL)
-	cachePath := filepath.Join(cacheBase, repoName)
-
-	logger.Info("preparing repository cache", "path", cachePath)
-
-	// Ensure base dir exists
-	if err := os.MkdirAll(cachePath, 0o755); err != nil {
-		return "", "", "", fmt.Errorf("failed to create cache path: %w", err)
+	cache, err := opts.cache()
+	if err != nil {
+		return nil, err
 	}
 
 	// Build auth if needed
-	authMethodObj, err := buildAuth(ctx, c, authMethod, authSecretRef)
+	authMethodObj, err := buildAuth(ctx, c, namespace, spec, opts)
+	if err != nil {
+		return nil, err
+	}
+	transportOpts, err := buildTransportOptions(ctx, c, namespace, spec)
 	if err != nil {
-		return "", "", "", err
+		return nil, err
 	}
 
-	// Determine clone vs open
-	var repo *git.Repository
+	fetchOpts := &git.FetchOptions{
+		RemoteName:   "origin",
+		RefSpecs:     fetchRefSpecs(spec),
+		Tags:         tagMode(spec),
+		Depth:        spec.Depth,
+		Force:        true,
+		CABundle:     transportOpts.CABundle,
+		ClientCert:   transportOpts.ClientCert,
+		ClientKey:    transportOpts.ClientKey,
+		ProxyOptions: transportOpts.Proxy,
+	}
+	if authMethodObj != nil {
+		fetchOpts.Auth = authMethodObj
+	}
 
-	gitDir := filepath.Join(cachePath, ".git")
-	_, statErr := os.Stat(gitDir)
+	repoKey := repoKeyFor(spec.RepoURL, spec.Depth)
+	unlock := cache.lock(repoKey)
+	defer unlock()
+
+	repo, err := fetchRepository(ctx, cache, repoKey, spec.RepoURL, fetchOpts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cache.recordRepository(repoKey); err != nil {
+		return nil, err
+	}
 
-	if os.IsNotExist(statErr) {
-		// Clone fresh
-		logger.Info("cloning repository", "url", repoURL, "branch", branch)
+	hash, tag, err := resolveCommit(repo, spec)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit object: %w", err)
+	}
 
-		cloneOpts := &git.CloneOptions{
-			URL:  repoURL,
-			Tags: git.AllTags,
+	// Verify under the repository lock, so that a concurrent fetch cannot
+	// replace the objects between verification and checkout
+	var signer string
+	if spec.Verify != nil {
+		signer, err = verifyRevision(ctx, c, namespace, cache.repoDir(repoKey), hash.String(), spec.Verify)
+		if err != nil {
+			return nil, err
 		}
-		if authMethodObj != nil {
-			cloneOpts.Auth = authMethodObj
+	}
+
+	// The size limit covers what this ConfigSync uses: the history of its
+	// commit and its checkout. The repository and other checkouts are shared,
+	// so an oversized sync leaves them to the cache eviction.
+	var historySize int64
+	if spec.MaxSize != nil {
+		if historySize, err = cache.historySize(repoKey, repo, commit); err != nil {
+			return nil, err
 		}
-		if branch != "" {
-			cloneOpts.SingleBranch = true
-			cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(branch)
+		if err := checkSize(spec.RepoURL, historySize, spec.MaxSize); err != nil {
+			return nil, err
 		}
+	}
 
-		repo, err = git.PlainClone(cachePath, false, cloneOpts)
-		if err != nil {
-			return "", "", "", fmt.Errorf("clone failed: %w", err)
+	logger.Info("checking out commit", "commit", hash.String(), "branch", spec.Branch, "tag", tag)
+	checkoutPath, checkoutSize, err := cache.checkout(repoKey, refKey(spec), commit, sparseCheckoutDirs(spec))
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.MaxSize != nil {
+		if err := checkSize(spec.RepoURL, historySize+checkoutSize, spec.MaxSize); err != nil {
+			return nil, err
 		}
+	}
 
-	} else {
-		// Repo exists → open & fetch
-		logger.Info("opening cached repository", "path", cachePath)
+	logger.Info("repository synced",
+		"repo", spec.RepoURL,
+		"commit", hash.String(),
+		"message", commit.Message,
+	)
 
-		repo, err = git.PlainOpen(cachePath)
-		if err != nil {
-			logger.Error(err, "cached repo appears corrupted; removing")
-			_ = os.RemoveAll(cachePath)
-			return cloneOrUpdate(ctx, c, repoURL, revision, branch, authMethod, authSecretRef)
-		}
+	return &Result{
+		Revision: hash.String(),
+		Path:     checkoutPath,
+		Message:  commit.Message,
+		Author:   fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email),
+		Tag:      tag,
+		Signer:   signer,
+		repoPath: cache.repoDir(repoKey),
+	}, nil
+}
 
-		logger.Info("fetching latest updates from origin")
+// fetchRepository fetches into the cached repository at repoKey, cloning it
+// first if needed. When a fetch fails, the cached repository is only discarded
+// and cloned again if it can no longer be read; transport and authentication
+// failures leave it in place. The caller must hold the repository lock.
+func fetchRepository(
+	ctx context.Context,
+	cache *Cache,
+	repoKey, repoURL string,
+	fetchOpts *git.FetchOptions,
+) (*git.Repository, error) {
 
-		fetchOpts := &git.FetchOptions{
-			RemoteName: "origin",
-			Tags:       git.AllTags,
-			Force:      true,
-		}
+	logger := log.FromContext(ctx)
 
-		if authMethodObj != nil {
-			fetchOpts.Auth = authMethodObj
-		}
+	repo, created, err := cache.openRepository(repoKey, repoURL)
+	if err != nil {
+		return nil, err
+	}
 
-		err = repo.Fetch(fetchOpts)
-		if err != nil && err != git.NoErrAlreadyUpToDate {
-			logger.Error(err, "fetch failed; repository may be corrupted")
-			_ = os.RemoveAll(cachePath)
-			return cloneOrUpdate(ctx, c, repoURL, revision, branch, authMethod, authSecretRef)
-		}
+	logger.Info("fetching repository", "url", repoURL, "path", cache.repoDir(repoKey), "clone", created)
+	err = repo.FetchContext(ctx, fetchOpts)
+	if err == nil || errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return repo, nil
 	}
 
-	w, err := repo.Worktree()
-	if err != nil {
-		return "", "", "", fmt.Errorf("failed to get worktree: %w", err)
+	if created {
+		_ = cache.remove(filepath.Join(reposDir, repoKey))
+		return nil, fmt.Errorf("clone failed: %w", err)
+	}
+	readErr := checkRepository(repo)
+	if readErr == nil {
+		return nil, fmt.Errorf("fetch failed: %w", err)
 	}
+	logger.Error(readErr, "cached repository is unreadable; cloning it again", "fetchError", err.Error())
+	if err := cache.remove(filepath.Join(reposDir, repoKey)); err != nil {
+		return nil, err
+	}
+	return fetchRepository(ctx, cache, repoKey, repoURL, fetchOpts)
+}
 
-	if revision != "" {
-		logger.Info("checking out revision", "revision", revision)
-		err = w.Checkout(&git.CheckoutOptions{
-			Hash: plumbing.NewHash(revision),
-		})
-		if err != nil {
-			return "", "", "", fmt.Errorf("checkout revision failed: %w", err)
-		}
-	} else if branch != "" {
-		logger.Info("checking out branch", "branch", branch)
-		// Prefer checking out the remote origin/<branch> reference (updated by Fetch),
-		// falling back to local branch checkout if the remote ref is not present.
-		remoteRef, refErr := repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
-		if refErr == nil {
-			err = w.Checkout(&git.CheckoutOptions{
-				Hash:  remoteRef.Hash(),
-				Force: true,
-			})
-		} else {
-			err = w.Checkout(&git.CheckoutOptions{
-				Branch: plumbing.NewBranchReferenceName(branch),
-			})
+// checkRepository reads every reference of repo and the commit it points at,
+// failing when the repository is corrupted.
+func checkRepository(repo *git.Repository) error {
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+	return refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
 		}
-		if err != nil {
-			return "", "", "", fmt.Errorf("checkout branch failed: %w", err)
+		if _, err := repo.Storer.EncodedObject(plumbing.AnyObject, ref.Hash()); err != nil {
+			return fmt.Errorf("failed to read %s: %w", ref.Name(), err)
 		}
-	}
+		return nil
+	})
+}
 
-	head, err := repo.Head()
-	if err != nil {
-		return "", "", "", fmt.Errorf("failed to read HEAD: %w", err)
+// fetchRefSpecs returns the refs to fetch for spec: the branch when set,
+// otherwise the remote's HEAD, along with all other branches unless
+// spec.SingleBranch is set.
+func fetchRefSpecs(spec *configsv1alpha1.GitSource) []gitconfig.RefSpec {
+	if spec.Branch != "" {
+		return []gitconfig.RefSpec{gitconfig.RefSpec(fmt.Sprintf(
+			"+%s:%s", plumbing.NewBranchReferenceName(spec.Branch), plumbing.NewRemoteReferenceName("origin", spec.Branch)))}
+	}
+	specs := []gitconfig.RefSpec{"+HEAD:refs/remotes/origin/HEAD"}
+	if !spec.SingleBranch {
+		specs = append(specs, "+refs/heads/*:refs/remotes/origin/*")
 	}
+	return specs
+}
 
-	commit, err := repo.CommitObject(head.Hash())
-	if err != nil {
-		return "", "", "", fmt.Errorf("failed to read commit object: %w", err)
+// refKey identifies the ref spec checks out within its repository.
+func refKey(spec *configsv1alpha1.GitSource) string {
+	key := fmt.Sprintf("branch=%s,revision=%s,sparse=%v:%s", spec.Branch, spec.Revision, spec.SparseCheckout, spec.Path)
+	if spec.Ref != nil {
+		key += fmt.Sprintf(",commit=%s,tag=%s,semver=%s", spec.Ref.Commit, spec.Ref.Tag, spec.Ref.Semver)
 	}
+	return key
+}
 
-	logger.Info("repository synced",
-		"repo", repoURL,
-		"commit", head.Hash().String(),
-		"message", commit.Message,
-	)
+// tagMode returns which tags to fetch for spec. Single-branch fetches skip
+// tags unless the ref or the signature verification needs them.
+func tagMode(spec *configsv1alpha1.GitSource) git.TagMode {
+	if !spec.SingleBranch {
+		return git.AllTags
+	}
+	if spec.Ref != nil && (spec.Ref.Tag != "" || spec.Ref.Semver != "") {
+		return git.AllTags
+	}
+	if spec.Verify != nil && spec.Verify.Mode == configsv1alpha1.VerificationModeTag {
+		return git.AllTags
+	}
+	return git.NoTags
+}
 
-	return head.Hash().String(), cachePath, commit.Message, nil
+// sparseCheckoutDirs returns the directories to check out when spec asks for
+// a sparse checkout, or nil for a full checkout.
+func sparseCheckoutDirs(spec *configsv1alpha1.GitSource) []string {
+	if !spec.SparseCheckout {
+		return nil
+	}
+	dir := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(spec.Path)), "/")
+	if dir == "" {
+		return nil
+	}
+	return []string{dir}
 }
 
 func sanitizeRepoURL(url string) string {
@@ -183,15 +271,21 @@ func firstSecretString(data map[string][]byte, keys []string) string {
 	return string(b)
 }
 
+// buildAuth returns the Git auth method configured by spec, reading the
+// Secret referenced by spec.AuthSecretRef. Its namespace defaults to
+// namespace, the ConfigSync's namespace.
 func buildAuth(
 	ctx context.Context,
 	c client.Client,
-	authMethod string,
-	authSecretRef *configsv1alpha1.ObjectRef,
+	namespace string,
+	spec *configsv1alpha1.GitSource,
+	opts Options,
 ) (gittransport.AuthMethod, error) {
 
 	logger := log.FromContext(ctx)
 
+	authMethod, authSecretRef := spec.AuthMethod, spec.AuthSecretRef
+
 	if strings.EqualFold(authMethod, "none") || authMethod == "" {
 		return nil, nil
 	}
@@ -200,13 +294,18 @@ func buildAuth(
 		return nil, fmt.Errorf("authSecretRef required for authMethod=%s", authMethod)
 	}
 
+	ns := authSecretRef.Namespace
+	if ns == "" {
+		ns = namespace
+	}
+
 	var secret corev1.Secret
 	if err := c.Get(ctx, types.NamespacedName{
-		Namespace: authSecretRef.Namespace,
+		Namespace: ns,
 		Name:      authSecretRef.Name,
 	}, &secret); err != nil {
 		return nil, fmt.Errorf("failed to read Secret %s/%s: %w",
-			authSecretRef.Namespace, authSecretRef.Name, err)
+			ns, authSecretRef.Name, err)
 	}
 
 	switch strings.ToLower(authMethod) {
@@ -216,10 +315,10 @@ func buildAuth(
 
 		if username == "" || password == "" {
 			return nil, fmt.Errorf("secret %s/%s missing username/password for https auth",
-				authSecretRef.Namespace, authSecretRef.Name)
+				ns, authSecretRef.Name)
 		}
 
-		logger.Info("using HTTPS basic auth: found username and password", "namespace", authSecretRef.Namespace, "name", authSecretRef.Name)
+		logger.Info("using HTTPS basic auth: found username and password", "namespace", ns, "name", authSecretRef.Name)
 		return &httpAuth.BasicAuth{
 			Username: username,
 			Password: password,
@@ -229,18 +328,37 @@ func buildAuth(
 		key := firstSecretKey(secret.Data, []string{"sshKey", "id_rsa", "ssh-privatekey", "private_key"})
 		if key == nil {
 			return nil, fmt.Errorf("secret %s/%s missing private key for ssh auth",
-				authSecretRef.Namespace, authSecretRef.Name)
+				ns, authSecretRef.Name)
 		}
 
-		logger.Info("using SSH key auth: found private key", "namespace", authSecretRef.Namespace, "name", authSecretRef.Name)
+		logger.Info("using SSH key auth: found private key", "namespace", ns, "name", authSecretRef.Name)
 
 		signer, err := sshAuth.NewPublicKeys("git", key, "")
 		if err != nil {
 			return nil, fmt.Errorf("failed to load ssh private key: %w", err)
 		}
 
-		// ADD SUPPORT FOR KNOWN HOSTS
-		signer.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+		if spec.InsecureSkipHostKeyVerification {
+			logger.Info("WARNING: SSH host key verification is disabled", "repo", spec.RepoURL)
+			signer.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+			return signer, nil
+		}
+
+		knownHosts := secret.Data[knownHostsKey]
+		if len(knownHosts) == 0 {
+			knownHosts, err = defaultKnownHosts(ctx, c, opts.KnownHosts)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(knownHosts) == 0 {
+			return nil, fmt.Errorf("no known_hosts for %s: add a %q key to Secret %s/%s or set insecureSkipHostKeyVerification",
+				spec.RepoURL, knownHostsKey, ns, authSecretRef.Name)
+		}
+		signer.HostKeyCallback, err = hostKeyCallback(knownHosts)
+		if err != nil {
+			return nil, err
+		}
 
 		return signer, nil
 
@@ -0,0 +1,256 @@
+package source
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"k8s.io/apimachinery/pkg/api/resource"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// newLargeRepo creates a bare repository holding commits commits. Each commit
+// rewrites a small manifest under deploy/ and files incompressible blobs under
+// assets/, so most of the history lies outside of deploy/.
+func newLargeRepo(tb testing.TB, commits, files int) string {
+	tb.Helper()
+	work := tb.TempDir()
+	runGit(tb, work, "init", "-q", "-b", "main")
+	for i := 0; i < commits; i++ {
+		tree := map[string]string{
+			"deploy/cm.yaml": fmt.Sprintf("kind: ConfigMap\ndata:\n  commit: %q\n", fmt.Sprint(i)),
+		}
+		for j := 0; j < files; j++ {
+			blob := make([]byte, 16<<10)
+			_, _ = rand.Read(blob)
+			tree[fmt.Sprintf("assets/%d.bin", j)] = hex.EncodeToString(blob)
+		}
+		writeTree(tb, work, tree)
+		runGit(tb, work, "add", ".")
+		runGit(tb, work, "commit", "-q", "-m", fmt.Sprintf("commit %d", i))
+	}
+
+	root := tb.TempDir()
+	runGit(tb, root, "clone", "-q", "--bare", work, "repo.git")
+	return filepath.Join(root, "repo.git")
+}
+
+// newTestCache returns options using a cache in a temporary directory.
+func newTestCache(tb testing.TB) Options {
+	tb.Helper()
+	cache, err := NewCache(CacheOptions{Root: tb.TempDir()})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return Options{Cache: cache}
+}
+
+func TestCloneOrUpdateShallowSparse(t *testing.T) {
+	repoURL := newLargeRepo(t, 3, 2)
+	spec := &configsv1alpha1.GitSource{
+		RepoURL:        repoURL,
+		Path:           "deploy",
+		AuthMethod:     "none",
+		Depth:          1,
+		SingleBranch:   true,
+		SparseCheckout: true,
+	}
+	opts := newTestCache(t)
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+
+	result, err := cloneOrUpdate(context.Background(), c, "default", spec, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(result.Path, "deploy", "cm.yaml")); err != nil {
+		t.Fatalf("expected deploy/cm.yaml to be checked out: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(result.Path, "assets")); !os.IsNotExist(err) {
+		t.Fatalf("expected assets/ to be left out of the sparse checkout, got %v", err)
+	}
+
+	repo, err := git.PlainOpen(result.repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shallow) != 1 || shallow[0].String() != result.Revision {
+		t.Fatalf("expected a shallow clone of %s, got %v", result.Revision, shallow)
+	}
+
+	// Asking for the full history uses a separate clone
+	spec.Depth = 0
+	result, err = cloneOrUpdate(context.Background(), c, "default", spec, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	repo, err = git.PlainOpen(result.repoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shallow, _ := repo.Storer.Shallow(); len(shallow) != 0 {
+		t.Fatalf("expected a full clone, got shallow commits %v", shallow)
+	}
+}
+
+func TestCloneOrUpdateMaxSize(t *testing.T) {
+	repoURL := newLargeRepo(t, 2, 2)
+	limit := resource.MustParse("64Ki")
+	spec := &configsv1alpha1.GitSource{
+		RepoURL:    repoURL,
+		Path:       "deploy",
+		AuthMethod: "none",
+		MaxSize:    &limit,
+	}
+	opts := newTestCache(t)
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+
+	_, err := cloneOrUpdate(context.Background(), c, "default", spec, opts)
+	if !errors.Is(err, ErrRepositoryTooLarge) {
+		t.Fatalf("expected ErrRepositoryTooLarge, got %v", err)
+	}
+
+	limit = resource.MustParse("1Gi")
+	if _, err := cloneOrUpdate(context.Background(), c, "default", spec, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCloneOrUpdateMaxSizeSharedRepository(t *testing.T) {
+	work := t.TempDir()
+	runGit(t, work, "init", "-q", "-b", "main")
+	writeTree(t, work, map[string]string{"deploy/cm.yaml": "kind: ConfigMap\n"})
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "-q", "-m", "small")
+	runGit(t, work, "checkout", "-q", "-b", "assets")
+	blob := make([]byte, 64<<10)
+	_, _ = rand.Read(blob)
+	writeTree(t, work, map[string]string{"assets/blob.bin": hex.EncodeToString(blob)})
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "-q", "-m", "large")
+
+	opts := newTestCache(t)
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+
+	large, err := cloneOrUpdate(context.Background(), c, "default",
+		&configsv1alpha1.GitSource{RepoURL: work, Branch: "assets", AuthMethod: "none"}, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The other branch's objects in the shared repository don't count
+	limit := resource.MustParse("16Ki")
+	small := &configsv1alpha1.GitSource{RepoURL: work, Branch: "main", AuthMethod: "none", MaxSize: &limit}
+	if _, err := cloneOrUpdate(context.Background(), c, "default", small, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Failing the limit leaves the shared cache alone
+	small.Branch = "assets"
+	if _, err := cloneOrUpdate(context.Background(), c, "default", small, opts); !errors.Is(err, ErrRepositoryTooLarge) {
+		t.Fatalf("expected ErrRepositoryTooLarge, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(large.Path, "assets", "blob.bin")); err != nil {
+		t.Fatalf("expected the checkout of the other ConfigSync to be kept: %v", err)
+	}
+	if _, err := git.PlainOpen(large.repoPath); err != nil {
+		t.Fatalf("expected the shared repository to be kept: %v", err)
+	}
+}
+
+func TestCloneOrUpdateKeepsRepositoryOnFetchFailure(t *testing.T) {
+	repoURL := newLargeRepo(t, 1, 1)
+	spec := &configsv1alpha1.GitSource{RepoURL: repoURL, AuthMethod: "none"}
+	opts := newTestCache(t)
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+
+	first, err := cloneOrUpdate(context.Background(), c, "default", spec, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// An unreachable remote leaves the cached repository alone
+	moved := repoURL + ".moved"
+	if err := os.Rename(repoURL, moved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cloneOrUpdate(context.Background(), c, "default", spec, opts); err == nil {
+		t.Fatal("expected the fetch to fail")
+	}
+	repo, err := git.PlainOpen(first.repoPath)
+	if err != nil {
+		t.Fatalf("expected the cached repository to be kept: %v", err)
+	}
+	if _, err := repo.CommitObject(plumbing.NewHash(first.Revision)); err != nil {
+		t.Fatalf("expected the cached commit to be kept: %v", err)
+	}
+
+	// A cached repository that can't be read is cloned again
+	if err := os.Rename(moved, repoURL); err != nil {
+		t.Fatal(err)
+	}
+	if packs, _ := os.ReadDir(filepath.Join(first.repoPath, "objects", "pack")); len(packs) == 0 {
+		t.Fatal("expected the cached objects to be packed")
+	}
+	if err := os.RemoveAll(filepath.Join(first.repoPath, "objects", "pack")); err != nil {
+		t.Fatal(err)
+	}
+	result, err := cloneOrUpdate(context.Background(), c, "default", spec, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Revision != first.Revision {
+		t.Fatalf("expected revision %s, got %s", first.Revision, result.Revision)
+	}
+}
+
+// BenchmarkCloneOrUpdate compares a full clone of a repository with a long
+// history against shallow and sparse clones of the same path. Besides the
+// time per clone, it reports the disk space taken by the cache.
+func BenchmarkCloneOrUpdate(b *testing.B) {
+	repoURL := newLargeRepo(b, 20, 10)
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+
+	for _, bc := range []struct {
+		name string
+		spec configsv1alpha1.GitSource
+	}{
+		{name: "full"},
+		{name: "shallow", spec: configsv1alpha1.GitSource{Depth: 1, SingleBranch: true}},
+		{name: "shallow-sparse", spec: configsv1alpha1.GitSource{Depth: 1, SingleBranch: true, SparseCheckout: true}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			spec := bc.spec
+			spec.RepoURL, spec.Path, spec.AuthMethod = repoURL, "deploy", "none"
+			opts := newTestCache(b)
+
+			var size int64
+			for i := 0; i < b.N; i++ {
+				if err := opts.RemoveCache(spec.RepoURL); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := cloneOrUpdate(context.Background(), c, "default", &spec, opts); err != nil {
+					b.Fatal(err)
+				}
+				var err error
+				if size, err = dirSize(opts.Cache.root); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(size), "disk-bytes")
+		})
+	}
+}
@@ -5,26 +5,108 @@ import (
 	"fmt"
 
 	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func FetchSource(configSync *configsv1alpha1.ConfigSync, ctx context.Context, c client.Client) (string, string, string, error) {
-	if configSync.Spec.Source.Git == nil {
-		return "", "", "", fmt.Errorf("only Git sources are supported; please set spec.source.git")
+// Options carry operator-wide settings for fetching sources.
+type Options struct {
+	// KnownHosts names a ConfigMap whose `known_hosts` key verifies SSH host
+	// keys for sources whose auth Secret carries none. Unset means no default.
+	KnownHosts types.NamespacedName
+	// Cache stores the fetched repositories. Nil means a cache under
+	// DefaultCacheRoot that is never evicted.
+	Cache *Cache
+}
+
+// Result describes a fetched source.
+type Result struct {
+	// Revision identifies the fetched content, for example a Git commit SHA.
+	Revision string
+	// Path is the local directory holding the fetched content.
+	Path string
+	// Message is the commit message of the fetched revision, if any.
+	Message string
+	// Author is the author of the fetched commit, if any.
+	Author string
+	// Tag is the tag that was resolved from spec.source.git.ref, if any.
+	Tag string
+	// Signer is the fingerprint of the key that signed the revision when
+	// verification is enabled.
+	Signer string
+
+	// repoPath is the cached repository the revision was fetched into.
+	repoPath string
+}
+
+func FetchSource(configSync *configsv1alpha1.ConfigSync, ctx context.Context, c client.Client, opts Options) (*Result, error) {
+	switch src := configSync.Spec.Source; {
+	case src.OCI != nil:
+		result, err := fetchOCI(ctx, c, configSync.Namespace, src.OCI)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pull OCI artifact: %w", err)
+		}
+		return result, nil
+	case src.HTTP != nil:
+		result, err := fetchHTTP(ctx, c, configSync.Namespace, src.HTTP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download archive: %w", err)
+		}
+		return result, nil
+	case src.Bucket != nil:
+		result, err := fetchBucket(ctx, c, configSync.Namespace, src.Bucket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download bucket: %w", err)
+		}
+		return result, nil
+	case src.ConfigMapRef != nil:
+		return fetchObject(ctx, c, configSync.Namespace, "ConfigMap", src.ConfigMapRef)
+	case src.SecretRef != nil:
+		return fetchObject(ctx, c, configSync.Namespace, "Secret", src.SecretRef)
+	case src.Git == nil:
+		return nil, fmt.Errorf("no source set; please set one of spec.source.git, oci, http, bucket, configMapRef or secretRef")
 	}
+	git := configSync.Spec.Source.Git
 
-	revisionSHA, sourcePath, commitMsg, err := cloneOrUpdate(
-		ctx,
-		c,
-		configSync.Spec.Source.Git.RepoURL,
-		configSync.Spec.Source.Git.Revision,
-		configSync.Spec.Source.Git.Branch,
-		configSync.Spec.Source.Git.AuthMethod,
-		configSync.Spec.Source.Git.AuthSecretRef,
-	)
+	result, err := cloneOrUpdate(ctx, c, configSync.Namespace, git, opts)
 	if err != nil {
-		return "", "", "", fmt.Errorf("failed to clone or update git repository: %w", err)
+		return nil, fmt.Errorf("failed to clone or update git repository: %w", err)
 	}
+	return result, nil
+}
 
-	return revisionSHA, sourcePath, commitMsg, nil
+// PinRevision changes spec to fetch revision, a Git commit SHA or an OCI
+// artifact digest, instead of following its ref. Other sources cannot fetch a
+// prior revision again and are rejected.
+func PinRevision(spec *configsv1alpha1.SourceSpec, revision string) error {
+	switch {
+	case spec.Git != nil:
+		spec.Git.Ref = &configsv1alpha1.GitRef{Commit: revision}
+		spec.Git.Revision = ""
+	case spec.OCI != nil:
+		spec.OCI.Ref = &configsv1alpha1.OCIRef{Digest: revision}
+	default:
+		return fmt.Errorf("only Git and OCI sources can be pinned to revision %s", revision)
+	}
+	return nil
+}
+
+// Selection returns the path within the fetched source and the include and
+// exclude patterns configured for the source in spec.
+func Selection(spec *configsv1alpha1.SourceSpec) (path string, include, exclude []string) {
+	switch {
+	case spec.OCI != nil:
+		return spec.OCI.Path, spec.OCI.Include, spec.OCI.Exclude
+	case spec.HTTP != nil:
+		return spec.HTTP.Path, spec.HTTP.Include, spec.HTTP.Exclude
+	case spec.Bucket != nil:
+		return spec.Bucket.Path, spec.Bucket.Include, spec.Bucket.Exclude
+	case spec.ConfigMapRef != nil:
+		return "", spec.ConfigMapRef.Include, spec.ConfigMapRef.Exclude
+	case spec.SecretRef != nil:
+		return "", spec.SecretRef.Include, spec.SecretRef.Exclude
+	case spec.Git != nil:
+		return spec.Git.Path, spec.Git.Include, spec.Git.Exclude
+	}
+	return "", nil, nil
 }
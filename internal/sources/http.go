@@ -0,0 +1,100 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+const (
+	// maxDownloadSize bounds archives read into memory and bucket downloads.
+	maxDownloadSize = 256 << 20
+	// downloadTimeout bounds each request of archive and bucket downloads.
+	downloadTimeout = 5 * time.Minute
+)
+
+// httpClient is used for archive and bucket downloads.
+var httpClient = &http.Client{Timeout: downloadTimeout}
+
+// fetchHTTP downloads the archive referenced by spec and extracts it into a
+// new temporary directory, which the caller must remove. The digest of the
+// extracted content becomes the result's revision.
+func fetchHTTP(ctx context.Context, c client.Client, namespace string, spec *configsv1alpha1.HTTPSource) (*Result, error) {
+	logger := log.FromContext(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spec.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", spec.URL, err)
+	}
+	if spec.HeadersSecretRef != nil {
+		var secret corev1.Secret
+		key := types.NamespacedName{Namespace: namespace, Name: spec.HeadersSecretRef.Name}
+		if err := c.Get(ctx, key, &secret); err != nil {
+			return nil, fmt.Errorf("failed to read Secret %s: %w", key, err)
+		}
+		for name, value := range secret.Data {
+			req.Header.Set(name, string(value))
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %s", spec.URL, resp.Status)
+	}
+	data, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", spec.URL, err)
+	}
+
+	if spec.Checksum != "" {
+		sum := sha256.Sum256(data)
+		if got := "sha256:" + hex.EncodeToString(sum[:]); got != spec.Checksum {
+			return nil, fmt.Errorf("%w: archive checksum is %s, expected %s", ErrVerificationFailed, got, spec.Checksum)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "config-sync-http-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create extraction directory: %w", err)
+	}
+	if err := extractArchive(data, dir); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to extract %s: %w", spec.URL, err)
+	}
+	revision, err := contentDigest(dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	logger.Info("archive downloaded", "url", spec.URL, "digest", revision)
+	return &Result{Revision: revision, Path: dir}, nil
+}
+
+// readLimited reads r, failing when it holds more than maxDownloadSize bytes.
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxDownloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDownloadSize {
+		return nil, fmt.Errorf("download exceeds %d bytes", maxDownloadSize)
+	}
+	return data, nil
+}
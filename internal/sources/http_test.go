@@ -0,0 +1,79 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+func TestFetchHTTP(t *testing.T) {
+	archive := tarGz(t, map[string]string{"deploy/cm.yaml": "cm"})
+	sum := sha256.Sum256(archive)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write(archive)
+	}))
+	t.Cleanup(srv.Close)
+	headers := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "headers", Namespace: "default"},
+		Data:       map[string][]byte{"Authorization": []byte("Bearer token")},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(headers).Build()
+
+	tests := []struct {
+		name      string
+		checksum  string
+		noHeaders bool
+		wantErr   string
+	}{
+		{name: "matching checksum", checksum: "sha256:" + hex.EncodeToString(sum[:])},
+		{name: "no checksum"},
+		{name: "mismatched checksum", checksum: "sha256:" + strings.Repeat("0", 64), wantErr: ErrVerificationFailed.Error()},
+		{name: "unauthenticated", noHeaders: true, wantErr: "401"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &configsv1alpha1.HTTPSource{URL: srv.URL + "/configs.tar.gz", Checksum: tt.checksum}
+			if !tt.noHeaders {
+				spec.HeadersSecretRef = &configsv1alpha1.LocalObjectReference{Name: "headers"}
+			}
+			result, err := fetchHTTP(context.Background(), c, "default", spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				if tt.checksum != "" && !errors.Is(err, ErrVerificationFailed) {
+					t.Fatalf("expected ErrVerificationFailed, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			t.Cleanup(func() { _ = os.RemoveAll(result.Path) })
+			if data, err := os.ReadFile(filepath.Join(result.Path, "deploy", "cm.yaml")); err != nil || string(data) != "cm" {
+				t.Fatalf("expected deploy/cm.yaml to be extracted, got %q (%v)", data, err)
+			}
+			if !strings.HasPrefix(result.Revision, "sha256:") {
+				t.Fatalf("expected a content digest as revision, got %q", result.Revision)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// knownHostsKey is the Secret and ConfigMap key holding trusted SSH host keys
+// in OpenSSH known_hosts format.
+const knownHostsKey = "known_hosts"
+
+// defaultKnownHosts reads the known_hosts key of the operator-wide ConfigMap
+// ref. It returns nil when ref is unset.
+func defaultKnownHosts(ctx context.Context, c client.Client, ref types.NamespacedName) ([]byte, error) {
+	if ref.Name == "" {
+		return nil, nil
+	}
+
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, ref, &cm); err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts ConfigMap %s: %w", ref, err)
+	}
+	return []byte(cm.Data[knownHostsKey]), nil
+}
+
+// hostKeyCallback returns a callback accepting only the host keys listed in
+// knownHosts. Unknown hosts and mismatching keys are rejected.
+func hostKeyCallback(knownHosts []byte) (ssh.HostKeyCallback, error) {
+	// knownhosts only reads from files; the file is parsed up front and can be
+	// removed right away
+	f, err := os.CreateTemp("", "known_hosts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write known_hosts: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.Write(knownHosts); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to write known_hosts: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write known_hosts: %w", err)
+	}
+
+	verify, err := knownhosts.New(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse known_hosts: %w", err)
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := verify(hostname, remote, key); err != nil {
+			return fmt.Errorf("host key verification failed for %s (%s %s): %w",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), err)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,122 @@
+package source
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"strings"
+	"testing"
+
+	sshAuth "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+func newKey(t *testing.T) (ssh.Signer, []byte) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, pem.EncodeToMemory(block)
+}
+
+func sshSpec(secret string) *configsv1alpha1.GitSource {
+	return &configsv1alpha1.GitSource{
+		RepoURL:       "git@git.example.com:org/configs.git",
+		AuthMethod:    "ssh",
+		AuthSecretRef: &configsv1alpha1.ObjectRef{Name: secret},
+	}
+}
+
+func TestBuildAuthVerifiesHostKeys(t *testing.T) {
+	_, clientKey := newKey(t)
+	hostKey, _ := newKey(t)
+	otherKey, _ := newKey(t)
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}
+	knownHostsLine := knownhosts.Line([]string{"git.example.com"}, hostKey.PublicKey()) + "\n"
+
+	objs := []client.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "with-known-hosts"},
+			Data:       map[string][]byte{"id_rsa": clientKey, "known_hosts": []byte(knownHostsLine)},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "key-only"},
+			Data:       map[string][]byte{"id_rsa": clientKey},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "config-sync-system", Name: "known-hosts"},
+			Data:       map[string]string{"known_hosts": knownHostsLine},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objs...).Build()
+	defaults := Options{KnownHosts: types.NamespacedName{Namespace: "config-sync-system", Name: "known-hosts"}}
+
+	tests := []struct {
+		name   string
+		secret string
+		opts   Options
+	}{
+		{name: "known_hosts in Secret", secret: "with-known-hosts"},
+		{name: "default ConfigMap", secret: "key-only", opts: defaults},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := buildAuth(context.Background(), c, "default", sshSpec(tt.secret), tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			callback := auth.(*sshAuth.PublicKeys).HostKeyCallback
+
+			if err := callback("git.example.com:22", addr, hostKey.PublicKey()); err != nil {
+				t.Fatalf("expected known host key to be accepted: %v", err)
+			}
+			err = callback("git.example.com:22", addr, otherKey.PublicKey())
+			if err == nil || !strings.Contains(err.Error(), "host key verification failed") {
+				t.Fatalf("expected mismatching host key to be rejected, got %v", err)
+			}
+			if err := callback("other.example.com:22", addr, hostKey.PublicKey()); err == nil {
+				t.Fatal("expected unknown host to be rejected")
+			}
+		})
+	}
+
+	t.Run("no known_hosts", func(t *testing.T) {
+		_, err := buildAuth(context.Background(), c, "default", sshSpec("key-only"), Options{})
+		if err == nil || !strings.Contains(err.Error(), "no known_hosts") {
+			t.Fatalf("expected missing known_hosts to fail, got %v", err)
+		}
+	})
+
+	t.Run("insecure", func(t *testing.T) {
+		spec := sshSpec("key-only")
+		spec.InsecureSkipHostKeyVerification = true
+		auth, err := buildAuth(context.Background(), c, "default", spec, Options{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		callback := auth.(*sshAuth.PublicKeys).HostKeyCallback
+		if err := callback("git.example.com:22", addr, otherKey.PublicKey()); err != nil {
+			t.Fatalf("expected any host key to be accepted: %v", err)
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package source
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	cacheSizeBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "configsync_source_cache_size_bytes",
+		Help: "Disk space taken by the source cache, by kind of entry (repository or checkout).",
+	}, []string{"kind"})
+
+	cacheEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "configsync_source_cache_entries",
+		Help: "Number of entries in the source cache, by kind of entry (repository or checkout).",
+	}, []string{"kind"})
+
+	cacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "configsync_source_cache_evictions_total",
+		Help: "Number of source cache entries evicted, by reason (ttl, superseded or size).",
+	}, []string{"reason"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(cacheSizeBytes, cacheEntries, cacheEvictions)
+}
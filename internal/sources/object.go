@@ -0,0 +1,67 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// fetchObject writes the keys of the ConfigMap or Secret (as selected by
+// kind) referenced by spec to files in a new temporary directory, which the
+// caller must remove. The digest of the written content becomes the
+// result's revision, so metadata-only updates do not trigger a sync.
+func fetchObject(ctx context.Context, c client.Client, namespace, kind string, spec *configsv1alpha1.ObjectSource) (*Result, error) {
+	logger := log.FromContext(ctx)
+	key := types.NamespacedName{Namespace: namespace, Name: spec.Name}
+
+	data := map[string][]byte{}
+	switch kind {
+	case "ConfigMap":
+		var cm corev1.ConfigMap
+		if err := c.Get(ctx, key, &cm); err != nil {
+			return nil, fmt.Errorf("failed to read ConfigMap %s: %w", key, err)
+		}
+		for k, v := range cm.Data {
+			data[k] = []byte(v)
+		}
+		for k, v := range cm.BinaryData {
+			data[k] = v
+		}
+	case "Secret":
+		var secret corev1.Secret
+		if err := c.Get(ctx, key, &secret); err != nil {
+			return nil, fmt.Errorf("failed to read Secret %s: %w", key, err)
+		}
+		data = secret.Data
+	default:
+		return nil, fmt.Errorf("unsupported source kind %q", kind)
+	}
+
+	dir, err := os.MkdirTemp("", "config-sync-object-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create source directory: %w", err)
+	}
+	for name, content := range data {
+		// The API server already bounds the size of the object
+		if err := writeExtracted(dir, name, bytes.NewReader(content), 0o644, nil); err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to write key %q of %s %s: %w", name, kind, key, err)
+		}
+	}
+	revision, err := contentDigest(dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	logger.Info("object read", "kind", kind, "name", key.String(), "keys", len(data), "digest", revision)
+	return &Result{Revision: revision, Path: dir}, nil
+}
@@ -0,0 +1,74 @@
+package source
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+func TestFetchObject(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "flags", Namespace: "default"},
+		Data:       map[string]string{"flags.yaml": "beta: true"},
+		BinaryData: map[string][]byte{"logo.png": {0x89, 'P', 'N', 'G'}},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "flags", Namespace: "default"},
+		Data:       map[string][]byte{"flags.yaml": []byte("beta: true"), "logo.png": {0x89, 'P', 'N', 'G'}},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(cm, secret).Build()
+	spec := &configsv1alpha1.ObjectSource{Name: "flags"}
+
+	var revisions []string
+	for _, kind := range []string{"ConfigMap", "Secret"} {
+		result, err := fetchObject(context.Background(), c, "default", kind, spec)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", kind, err)
+		}
+		t.Cleanup(func() { _ = os.RemoveAll(result.Path) })
+		if data, err := os.ReadFile(filepath.Join(result.Path, "flags.yaml")); err != nil || string(data) != "beta: true" {
+			t.Fatalf("%s: expected flags.yaml to be written, got %q (%v)", kind, data, err)
+		}
+		if _, err := os.Stat(filepath.Join(result.Path, "logo.png")); err != nil {
+			t.Fatalf("%s: expected logo.png to be written: %v", kind, err)
+		}
+		revisions = append(revisions, result.Revision)
+	}
+	if revisions[0] != revisions[1] {
+		t.Fatalf("expected equal content to yield equal revisions, got %v", revisions)
+	}
+
+	// Metadata changes leave the revision alone; content changes don't
+	cm.Labels = map[string]string{"team": "platform"}
+	if err := c.Update(context.Background(), cm); err != nil {
+		t.Fatal(err)
+	}
+	result, err := fetchObject(context.Background(), c, "default", "ConfigMap", spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = os.RemoveAll(result.Path)
+	if result.Revision != revisions[0] {
+		t.Fatalf("expected a label change to keep revision %s, got %s", revisions[0], result.Revision)
+	}
+	cm.Data["flags.yaml"] = "beta: false"
+	if err := c.Update(context.Background(), cm); err != nil {
+		t.Fatal(err)
+	}
+	result, err = fetchObject(context.Background(), c, "default", "ConfigMap", spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = os.RemoveAll(result.Path)
+	if result.Revision == revisions[0] {
+		t.Fatal("expected a content change to yield a new revision")
+	}
+}
@@ -0,0 +1,216 @@
+package source
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// defaultOCITag is pulled when spec.source.oci.ref is unset.
+const defaultOCITag = "latest"
+
+// fetchOCI pulls the artifact referenced by spec and extracts its layers into
+// a new temporary directory, which the caller must remove. The manifest and
+// every layer are checked against their digests while they are read, and the
+// manifest digest becomes the result's revision.
+func fetchOCI(ctx context.Context, c client.Client, namespace string, spec *configsv1alpha1.OCISource) (*Result, error) {
+	logger := log.FromContext(ctx)
+
+	repo, err := remote.NewRepository(strings.TrimPrefix(spec.URL, "oci://"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCI repository %q: %w", spec.URL, err)
+	}
+	repo.PlainHTTP = spec.Insecure
+	authClient := &auth.Client{Client: retry.DefaultClient, Cache: auth.NewCache()}
+	if spec.SecretRef != nil {
+		cred, err := registryCredential(ctx, c, namespace, spec.SecretRef.Name, repo.Reference.Registry)
+		if err != nil {
+			return nil, err
+		}
+		authClient.Credential = auth.StaticCredential(repo.Reference.Registry, cred)
+	}
+	repo.Client = authClient
+
+	reference, tag, err := resolveOCIRef(ctx, repo, spec.Ref)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := repo.Resolve(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s:%s: %w", spec.URL, reference, err)
+	}
+	if spec.Ref != nil && spec.Ref.Digest != "" && desc.Digest.String() != spec.Ref.Digest {
+		return nil, fmt.Errorf("%w: registry resolved %s to %s", ErrVerificationFailed, spec.Ref.Digest, desc.Digest)
+	}
+	data, err := fetchBlob(ctx, repo, desc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest %s: %w", desc.Digest, err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", desc.Digest, err)
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, fmt.Errorf("artifact %s has no layers", desc.Digest)
+	}
+
+	dir, err := os.MkdirTemp("", "config-sync-oci-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create extraction directory: %w", err)
+	}
+	budget := newSizeBudget(maxExtractedSize)
+	for _, layer := range manifest.Layers {
+		if err := extractLayer(ctx, repo, layer, dir, budget); err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to extract layer %s: %w", layer.Digest, err)
+		}
+	}
+
+	logger.Info("artifact pulled", "url", spec.URL, "digest", desc.Digest.String(), "tag", tag)
+	return &Result{Revision: desc.Digest.String(), Path: dir, Tag: tag}, nil
+}
+
+// resolveOCIRef returns the reference to resolve for ref and the tag it
+// selects, if any. A semver range is resolved against the repository's tags.
+func resolveOCIRef(ctx context.Context, repo *remote.Repository, ref *configsv1alpha1.OCIRef) (string, string, error) {
+	switch {
+	case ref == nil:
+		return defaultOCITag, defaultOCITag, nil
+	case ref.Digest != "":
+		return ref.Digest, "", nil
+	case ref.Tag != "":
+		return ref.Tag, ref.Tag, nil
+	case ref.Semver != "":
+		var tags []string
+		err := repo.Tags(ctx, "", func(page []string) error {
+			tags = append(tags, page...)
+			return nil
+		})
+		if err != nil {
+			return "", "", fmt.Errorf("failed to list tags: %w", err)
+		}
+		tag, err := highestVersion(tags, ref.Semver)
+		if err != nil {
+			return "", "", err
+		}
+		return tag, tag, nil
+	}
+	return defaultOCITag, defaultOCITag, nil
+}
+
+// fetchBlob reads the content described by desc, failing with
+// ErrVerificationFailed when it does not match the descriptor's digest.
+// Descriptors larger than maxDownloadSize are rejected before fetching.
+func fetchBlob(ctx context.Context, repo *remote.Repository, desc ocispec.Descriptor) ([]byte, error) {
+	if desc.Size > maxDownloadSize {
+		return nil, fmt.Errorf("blob %s of %d bytes exceeds %d bytes", desc.Digest, desc.Size, maxDownloadSize)
+	}
+	rc, err := repo.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+	data, err := content.ReadAll(rc, desc)
+	if errors.Is(err, content.ErrMismatchedDigest) || errors.Is(err, content.ErrTrailingData) {
+		return nil, fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+	}
+	return data, err
+}
+
+// extractLayer writes layer to dir, charging the written files to budget:
+// tarballs are unpacked, other layers are stored under their title
+// annotation.
+func extractLayer(ctx context.Context, repo *remote.Repository, layer ocispec.Descriptor, dir string, budget *sizeBudget) error {
+	data, err := fetchBlob(ctx, repo, layer)
+	if err != nil {
+		return err
+	}
+
+	mediaType := layer.MediaType
+	switch {
+	case strings.HasSuffix(mediaType, "tar+gzip") || strings.HasSuffix(mediaType, "tar.gzip"):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		return extractTar(gz, dir, budget)
+	case strings.HasSuffix(mediaType, ".tar"):
+		return extractTar(bytes.NewReader(data), dir, budget)
+	}
+
+	title := layer.Annotations[ocispec.AnnotationTitle]
+	if title == "" {
+		return fmt.Errorf("layer of media type %q is neither a tarball nor titled", mediaType)
+	}
+	return writeExtracted(dir, title, bytes.NewReader(data), 0o644, budget)
+}
+
+// dockerConfig is the content of a `kubernetes.io/dockerconfigjson` Secret.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	} `json:"auths"`
+}
+
+// registryCredential reads the credential for registry from the docker
+// config Secret name in namespace.
+func registryCredential(ctx context.Context, c client.Client, namespace, name, registry string) (auth.Credential, error) {
+	var secret corev1.Secret
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+		return auth.EmptyCredential, fmt.Errorf("failed to read Secret %s/%s: %w", namespace, name, err)
+	}
+	data, ok := secret.Data[corev1.DockerConfigJsonKey]
+	if !ok {
+		return auth.EmptyCredential, fmt.Errorf("secret %s/%s has no %s key", namespace, name, corev1.DockerConfigJsonKey)
+	}
+	var config dockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return auth.EmptyCredential, fmt.Errorf("failed to parse %s of Secret %s/%s: %w", corev1.DockerConfigJsonKey, namespace, name, err)
+	}
+
+	for server, entry := range config.Auths {
+		if registryHost(server) != registry {
+			continue
+		}
+		username, password := entry.Username, entry.Password
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return auth.EmptyCredential, fmt.Errorf("invalid auth for %s in Secret %s/%s: %w", server, namespace, name, err)
+			}
+			username, password, _ = strings.Cut(string(decoded), ":")
+		}
+		return auth.Credential{Username: username, Password: password}, nil
+	}
+	return auth.EmptyCredential, fmt.Errorf("secret %s/%s has no credentials for %s", namespace, name, registry)
+}
+
+// registryHost strips the scheme and path that docker config keys may carry.
+func registryHost(server string) string {
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		return u.Host
+	}
+	host, _, _ := strings.Cut(server, "/")
+	return host
+}
@@ -0,0 +1,220 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// testRegistry is an in-memory OCI registry requiring basic auth.
+type testRegistry struct {
+	host string
+	// corrupt makes the registry serve altered blobs.
+	corrupt bool
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	reg := &testRegistry{}
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "ci" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if reg.corrupt && r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			body := rec.Body.Bytes()
+			body[len(body)-1] ^= 0xff
+			w.WriteHeader(rec.Code)
+			_, _ = w.Write(body)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.host = u.Host
+	return reg
+}
+
+// push packages files as a gzipped tarball layer and pushes it to the
+// repository name under tag.
+func (reg *testRegistry) push(t *testing.T, name, tag string, files map[string]string) ocispec.Descriptor {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for path, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: path, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	repo, err := remote.NewRepository(reg.host + "/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.PlainHTTP = true
+	repo.Client = &auth.Client{Credential: auth.StaticCredential(reg.host, auth.Credential{Username: "ci", Password: "secret"})}
+
+	layer := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(buf.Bytes()),
+		Size:      int64(buf.Len()),
+	}
+	if err := repo.Push(ctx, layer, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := oras.PackManifest(ctx, repo, oras.PackManifestVersion1_1, "application/vnd.example.config.v1",
+		oras.PackManifestOptions{Layers: []ocispec.Descriptor{layer}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Tag(ctx, manifest, tag); err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
+
+// dockerConfigSecret returns a Secret holding the test registry credentials.
+func (reg *testRegistry) dockerConfigSecret(t *testing.T) *corev1.Secret {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			"https://" + reg.host + "/v1/": map[string]string{"username": "ci", "password": "secret"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: "default"},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: data},
+	}
+}
+
+func TestFetchOCI(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.push(t, "configs", "v1.0.0", map[string]string{"deploy/cm.yaml": "v1.0.0"})
+	v110 := reg.push(t, "configs", "v1.1.0", map[string]string{"deploy/cm.yaml": "v1.1.0"})
+	reg.push(t, "configs", "v2.0.0", map[string]string{"deploy/cm.yaml": "v2.0.0"})
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(reg.dockerConfigSecret(t)).Build()
+
+	tests := []struct {
+		name     string
+		ref      *configsv1alpha1.OCIRef
+		noSecret bool
+		want     string
+		wantTag  string
+		wantErr  string
+	}{
+		{name: "semver range", ref: &configsv1alpha1.OCIRef{Semver: "~1"}, want: "v1.1.0", wantTag: "v1.1.0"},
+		{name: "tag", ref: &configsv1alpha1.OCIRef{Tag: "v2.0.0"}, want: "v2.0.0", wantTag: "v2.0.0"},
+		{name: "digest", ref: &configsv1alpha1.OCIRef{Digest: v110.Digest.String(), Tag: "v2.0.0"}, want: "v1.1.0"},
+		{name: "default tag", wantErr: "latest"},
+		{name: "unauthenticated", ref: &configsv1alpha1.OCIRef{Tag: "v2.0.0"}, noSecret: true, wantErr: "credential not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &configsv1alpha1.OCISource{URL: "oci://" + reg.host + "/configs", Ref: tt.ref, Insecure: true}
+			if !tt.noSecret {
+				spec.SecretRef = &configsv1alpha1.LocalObjectReference{Name: "registry"}
+			}
+			result, err := fetchOCI(context.Background(), c, "default", spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			t.Cleanup(func() { _ = os.RemoveAll(result.Path) })
+
+			data, err := os.ReadFile(filepath.Join(result.Path, "deploy", "cm.yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want || result.Tag != tt.wantTag {
+				t.Fatalf("expected %s (tag %q), got %s (tag %q)", tt.want, tt.wantTag, data, result.Tag)
+			}
+			if !strings.HasPrefix(result.Revision, "sha256:") {
+				t.Fatalf("expected the manifest digest as revision, got %q", result.Revision)
+			}
+		})
+	}
+
+	t.Run("corrupted blob", func(t *testing.T) {
+		reg.corrupt = true
+		defer func() { reg.corrupt = false }()
+		spec := &configsv1alpha1.OCISource{
+			URL:       "oci://" + reg.host + "/configs",
+			Ref:       &configsv1alpha1.OCIRef{Tag: "v1.0.0"},
+			SecretRef: &configsv1alpha1.LocalObjectReference{Name: "registry"},
+			Insecure:  true,
+		}
+		_, err := fetchOCI(context.Background(), c, "default", spec)
+		if !errors.Is(err, ErrVerificationFailed) {
+			t.Fatalf("expected ErrVerificationFailed, got %v", err)
+		}
+	})
+	t.Run("oversized blob", func(t *testing.T) {
+		repo, err := remote.NewRepository(reg.host + "/configs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo.PlainHTTP = true
+		desc := ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageLayerGzip,
+			Digest:    digest.FromString("bomb"),
+			Size:      maxDownloadSize + 1,
+		}
+		_, err = fetchBlob(context.Background(), repo, desc)
+		if err == nil || !strings.Contains(err.Error(), "exceeds") {
+			t.Fatalf("expected the oversized blob to be rejected, got %v", err)
+		}
+	})
+}
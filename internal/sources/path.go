@@ -0,0 +1,142 @@
+package source
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ResolvePath joins the repository-relative path onto root and returns the
+// resulting absolute path with all symlinks evaluated. It fails when the path
+// is absolute, climbs out of root via "..", or resolves (through a symlink)
+// to a location outside of root.
+func ResolvePath(root, path string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(path))
+	if filepath.IsAbs(cleaned) || !filepath.IsLocal(cleaned) {
+		return "", fmt.Errorf("path %q escapes the repository root", path)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve repository root: %w", err)
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Join(realRoot, cleaned))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("path %q does not exist in the repository", path)
+		}
+		return "", fmt.Errorf("failed to resolve path %q: %w", path, err)
+	}
+
+	rel, err := filepath.Rel(realRoot, resolved)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path %q resolves outside of the repository root", path)
+	}
+
+	return resolved, nil
+}
+
+// CollectFiles returns the files selected by path within the repository at root.
+// If path is a file, it is returned as the only entry. If path is a directory,
+// it is walked recursively in lexical order and every regular file whose path
+// relative to the directory matches the include globs (all files when empty)
+// and none of the exclude globs is returned. The .git directory is never
+// walked, symlinked directories are not followed, and symlinked files are
+// only accepted when their target stays inside the repository.
+func CollectFiles(root, path string, include, exclude []string) ([]string, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("invalid glob pattern %q", pattern)
+		}
+	}
+
+	base, err := ResolvePath(root, path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{base}, nil
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve repository root: %w", err)
+	}
+
+	var files []string
+	err = filepath.WalkDir(base, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		if !matchesGlobs(filepath.ToSlash(rel), include, exclude) {
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			repoRel, err := filepath.Rel(realRoot, p)
+			if err != nil {
+				return err
+			}
+			target, err := ResolvePath(realRoot, repoRel)
+			if err != nil {
+				return err
+			}
+			targetInfo, err := os.Stat(target)
+			if err != nil {
+				return fmt.Errorf("failed to stat symlink target of %s: %w", repoRel, err)
+			}
+			if targetInfo.IsDir() {
+				return nil
+			}
+		} else if !d.Type().IsRegular() {
+			return nil
+		}
+
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", path, err)
+	}
+
+	return files, nil
+}
+
+// matchesGlobs reports whether rel is selected by the include and exclude
+// patterns. Patterns were validated up front, so match errors cannot occur.
+func matchesGlobs(rel string, include, exclude []string) bool {
+	for _, pattern := range exclude {
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTree(t testing.TB, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func relPaths(t *testing.T, root string, files []string) []string {
+	t.Helper()
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]string, 0, len(files))
+	for _, f := range files {
+		rel, err := filepath.Rel(realRoot, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, filepath.ToSlash(rel))
+	}
+	return out
+}
+
+func TestCollectFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".git/config":                  "",
+		"README.md":                    "",
+		"deploy/app/deployment.yaml":   "",
+		"deploy/app/service.yml":       "",
+		"deploy/app/nested/cm.yaml":    "",
+		"deploy/app/nested/notes.txt":  "",
+		"deploy/other/deployment.yaml": "",
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		include []string
+		exclude []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "repository root skips .git",
+			path: ".",
+			want: []string{
+				"README.md",
+				"deploy/app/deployment.yaml",
+				"deploy/app/nested/cm.yaml",
+				"deploy/app/nested/notes.txt",
+				"deploy/app/service.yml",
+				"deploy/other/deployment.yaml",
+			},
+		},
+		{
+			name: "subdirectory is walked recursively",
+			path: "deploy/app",
+			want: []string{
+				"deploy/app/deployment.yaml",
+				"deploy/app/nested/cm.yaml",
+				"deploy/app/nested/notes.txt",
+				"deploy/app/service.yml",
+			},
+		},
+		{
+			name: "single file",
+			path: "deploy/app/service.yml",
+			want: []string{"deploy/app/service.yml"},
+		},
+		{
+			name:    "include and exclude globs",
+			path:    "deploy/app",
+			include: []string{"**/*.yaml"},
+			exclude: []string{"nested/**"},
+			want:    []string{"deploy/app/deployment.yaml"},
+		},
+		{
+			name:    "parent traversal is rejected",
+			path:    "deploy/../../etc",
+			wantErr: true,
+		},
+		{
+			name:    "absolute path is rejected",
+			path:    "/etc",
+			wantErr: true,
+		},
+		{
+			name:    "missing path",
+			path:    "deploy/missing",
+			wantErr: true,
+		},
+		{
+			name:    "invalid glob",
+			path:    "deploy",
+			include: []string{"[a-"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := CollectFiles(root, tt.path, tt.include, tt.exclude)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got files %v", files)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := relPaths(t, root, files); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectFilesSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	writeTree(t, outside, map[string]string{"secret.yaml": ""})
+
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"common/base.yaml":      "",
+		"deploy/app/main.yaml":  "",
+		"deploy/evil/main.yaml": "",
+	})
+	if err := os.Symlink(filepath.Join(root, "common", "base.yaml"), filepath.Join(root, "deploy", "app", "base.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.yaml"), filepath.Join(root, "deploy", "evil", "secret.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := CollectFiles(root, "deploy/app", nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"deploy/app/base.yaml", "deploy/app/main.yaml"}
+	if got := relPaths(t, root, files); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if _, err := CollectFiles(root, "deploy/evil", nil, nil); err == nil {
+		t.Fatal("expected symlink escaping the repository to be rejected")
+	}
+	if _, err := CollectFiles(root, "escape", nil, nil); err == nil {
+		t.Fatal("expected symlinked path outside the repository to be rejected")
+	}
+}
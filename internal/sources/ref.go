@@ -0,0 +1,137 @@
+package source
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Masterminds/semver/v3"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// commitSHA matches a full hexadecimal commit SHA.
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// resolveRef resolves spec.ref (or the legacy spec.revision) against the
+// fetched repository. In order of precedence, a commit is used as-is, a tag
+// is looked up by name and a semver range picks the highest matching tag. It
+// reports false when neither is set, in which case the branch is checked out.
+func resolveRef(repo *git.Repository, spec *configsv1alpha1.GitSource) (plumbing.Hash, string, bool, error) {
+	var ref configsv1alpha1.GitRef
+	if spec.Ref != nil {
+		ref = *spec.Ref
+	}
+	if ref.Commit == "" {
+		ref.Commit = spec.Revision
+	}
+
+	switch {
+	case ref.Commit != "":
+		if !commitSHA.MatchString(ref.Commit) {
+			return plumbing.ZeroHash, "", false, fmt.Errorf("commit %q is not a full commit SHA; use ref.tag or ref.semver for tags", ref.Commit)
+		}
+		hash := plumbing.NewHash(ref.Commit)
+		if _, err := repo.CommitObject(hash); err != nil {
+			return plumbing.ZeroHash, "", false, fmt.Errorf("commit %s not found: %w", ref.Commit, err)
+		}
+		return hash, "", true, nil
+	case ref.Tag != "":
+		hash, err := tagCommit(repo, ref.Tag)
+		if err != nil {
+			return plumbing.ZeroHash, "", false, err
+		}
+		return hash, ref.Tag, true, nil
+	case ref.Semver != "":
+		tag, err := latestTag(repo, ref.Semver)
+		if err != nil {
+			return plumbing.ZeroHash, "", false, err
+		}
+		hash, err := tagCommit(repo, tag)
+		if err != nil {
+			return plumbing.ZeroHash, "", false, err
+		}
+		return hash, tag, true, nil
+	}
+	return plumbing.ZeroHash, "", false, nil
+}
+
+// tagCommit returns the commit the tag name points at, peeling annotated tags.
+func tagCommit(repo *git.Repository, name string) (plumbing.Hash, error) {
+	ref, err := repo.Tag(name)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("tag %s not found: %w", name, err)
+	}
+	if tag, err := repo.TagObject(ref.Hash()); err == nil {
+		commit, err := tag.Commit()
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("tag %s does not point at a commit: %w", name, err)
+		}
+		return commit.Hash, nil
+	}
+	return ref.Hash(), nil
+}
+
+// latestTag returns the tag with the highest semantic version satisfying
+// constraint.
+func latestTag(repo *git.Repository, constraint string) (string, error) {
+	tags, err := repo.Tags()
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags: %w", err)
+	}
+	var names []string
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		names = append(names, ref.Name().Short())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags: %w", err)
+	}
+	return highestVersion(names, constraint)
+}
+
+// highestVersion returns the tag with the highest semantic version satisfying
+// constraint. Tags that are not semantic versions are ignored.
+func highestVersion(tags []string, constraint string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid semver range %q: %w", constraint, err)
+	}
+
+	var best *semver.Version
+	var bestTag string
+	for _, name := range tags {
+		v, err := semver.NewVersion(name)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if best == nil || v.GreaterThan(best) {
+			best, bestTag = v, name
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("no tag matches semver range %q", constraint)
+	}
+	return bestTag, nil
+}
+
+// resolveCommit returns the commit to check out for spec: the one spec.ref
+// resolves to when set, otherwise the tip of the branch, or of the remote's
+// default branch when no branch is set either.
+func resolveCommit(repo *git.Repository, spec *configsv1alpha1.GitSource) (plumbing.Hash, string, error) {
+	hash, tag, resolved, err := resolveRef(repo, spec)
+	if err != nil || resolved {
+		return hash, tag, err
+	}
+
+	name := plumbing.NewRemoteReferenceName("origin", "HEAD")
+	if spec.Branch != "" {
+		name = plumbing.NewRemoteReferenceName("origin", spec.Branch)
+	}
+	ref, err := repo.Reference(name, true)
+	if err != nil {
+		return plumbing.ZeroHash, "", fmt.Errorf("failed to resolve %s: %w", name.Short(), err)
+	}
+	return ref.Hash(), "", nil
+}
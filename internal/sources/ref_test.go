@@ -0,0 +1,176 @@
+package source
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// taggedRepo is a local repository whose commits are tagged with versions.
+type taggedRepo struct {
+	t    *testing.T
+	dir  string
+	repo *git.Repository
+}
+
+func newTaggedRepo(t *testing.T) *taggedRepo {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &taggedRepo{t: t, dir: dir, repo: repo}
+}
+
+// commitTagged commits a file holding version and tags the commit with it.
+// Annotated tags are used when annotated is set.
+func (r *taggedRepo) commitTagged(version string, annotated bool) plumbing.Hash {
+	r.t.Helper()
+	writeTree(r.t, r.dir, map[string]string{"manifests/version.txt": version})
+	w, err := r.repo.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if _, err := w.Add("manifests/version.txt"); err != nil {
+		r.t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	hash, err := w.Commit(version, &git.CommitOptions{Author: sig})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	var opts *git.CreateTagOptions
+	if annotated {
+		opts = &git.CreateTagOptions{Tagger: sig, Message: version}
+	}
+	if _, err := r.repo.CreateTag(version, hash, opts); err != nil {
+		r.t.Fatal(err)
+	}
+	return hash
+}
+
+func TestResolveRef(t *testing.T) {
+	r := newTaggedRepo(t)
+	v100 := r.commitTagged("v1.0.0", true)
+	v120 := r.commitTagged("v1.2.0", false)
+	r.commitTagged("v2.0.0", true)
+	r.commitTagged("not-a-version", false)
+
+	tests := []struct {
+		name     string
+		spec     configsv1alpha1.GitSource
+		wantHash plumbing.Hash
+		wantTag  string
+		wantErr  string
+	}{
+		{name: "annotated tag", spec: configsv1alpha1.GitSource{Ref: &configsv1alpha1.GitRef{Tag: "v1.0.0"}}, wantHash: v100, wantTag: "v1.0.0"},
+		{name: "lightweight tag", spec: configsv1alpha1.GitSource{Ref: &configsv1alpha1.GitRef{Tag: "v1.2.0"}}, wantHash: v120, wantTag: "v1.2.0"},
+		{name: "semver range", spec: configsv1alpha1.GitSource{Ref: &configsv1alpha1.GitRef{Semver: ">=1.0.0 <2.0.0"}}, wantHash: v120, wantTag: "v1.2.0"},
+		{name: "commit", spec: configsv1alpha1.GitSource{Ref: &configsv1alpha1.GitRef{Commit: v100.String(), Tag: "v1.2.0"}}, wantHash: v100},
+		{name: "legacy revision", spec: configsv1alpha1.GitSource{Revision: v100.String()}, wantHash: v100},
+		{name: "tag as revision", spec: configsv1alpha1.GitSource{Revision: "v1.0.0"}, wantErr: "not a full commit SHA"},
+		{name: "unknown tag", spec: configsv1alpha1.GitSource{Ref: &configsv1alpha1.GitRef{Tag: "v9.9.9"}}, wantErr: "tag v9.9.9 not found"},
+		{name: "empty range", spec: configsv1alpha1.GitSource{Ref: &configsv1alpha1.GitRef{Semver: ">=3.0.0"}}, wantErr: "no tag matches"},
+		{name: "invalid range", spec: configsv1alpha1.GitSource{Ref: &configsv1alpha1.GitRef{Semver: "latest"}}, wantErr: "invalid semver range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, tag, resolved, err := resolveRef(r.repo, &tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !resolved || hash != tt.wantHash || tag != tt.wantTag {
+				t.Fatalf("got (%s, %q, %v), want (%s, %q, true)", hash, tag, resolved, tt.wantHash, tt.wantTag)
+			}
+		})
+	}
+
+	if _, _, resolved, err := resolveRef(r.repo, &configsv1alpha1.GitSource{Branch: "main"}); resolved || err != nil {
+		t.Fatalf("expected a branch to be left unresolved, got %v, %v", resolved, err)
+	}
+}
+
+func TestCloneOrUpdateFloatsOnSemverRange(t *testing.T) {
+	r := newTaggedRepo(t)
+	r.commitTagged("v1.0.0", true)
+	v110 := r.commitTagged("v1.1.0", true)
+	r.commitTagged("v2.0.0", true)
+
+	spec := &configsv1alpha1.GitSource{
+		RepoURL:    r.dir,
+		AuthMethod: "none",
+		Ref:        &configsv1alpha1.GitRef{Semver: "~1"},
+	}
+	t.Cleanup(func() { _ = Options{}.RemoveCache(spec.RepoURL) })
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+
+	result, err := cloneOrUpdate(context.Background(), c, "default", spec, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Revision != v110.String() || result.Tag != "v1.1.0" {
+		t.Fatalf("expected v1.1.0 at %s, got %s at %s", v110, result.Tag, result.Revision)
+	}
+
+	// Release a patch on top of v1.1.0
+	w, err := r.repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Hash: v110}); err != nil {
+		t.Fatal(err)
+	}
+	v111 := r.commitTagged("v1.1.1", false)
+
+	result, err = cloneOrUpdate(context.Background(), c, "default", spec, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Revision != v111.String() || result.Tag != "v1.1.1" {
+		t.Fatalf("expected v1.1.1 at %s, got %s at %s", v111, result.Tag, result.Revision)
+	}
+}
+
+func TestPinRevision(t *testing.T) {
+	const commit = "0123456789abcdef0123456789abcdef01234567"
+	gitSpec := &configsv1alpha1.SourceSpec{Git: &configsv1alpha1.GitSource{
+		Revision: "fedcba9876543210fedcba9876543210fedcba98",
+		Ref:      &configsv1alpha1.GitRef{Semver: "~1"},
+	}}
+	if err := PinRevision(gitSpec, commit); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gitSpec.Git.Revision != "" || *gitSpec.Git.Ref != (configsv1alpha1.GitRef{Commit: commit}) {
+		t.Fatalf("expected the Git source to be pinned to %s, got revision %q and ref %+v", commit, gitSpec.Git.Revision, *gitSpec.Git.Ref)
+	}
+
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	oci := &configsv1alpha1.SourceSpec{OCI: &configsv1alpha1.OCISource{Ref: &configsv1alpha1.OCIRef{Tag: "v1"}}}
+	if err := PinRevision(oci, digest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *oci.OCI.Ref != (configsv1alpha1.OCIRef{Digest: digest}) {
+		t.Fatalf("expected the OCI source to be pinned to %s, got %+v", digest, *oci.OCI.Ref)
+	}
+
+	archive := &configsv1alpha1.SourceSpec{HTTP: &configsv1alpha1.HTTPSource{URL: "https://example.com/configs.tar.gz"}}
+	if err := PinRevision(archive, digest); err == nil || !strings.Contains(err.Error(), "only Git and OCI") {
+		t.Fatalf("expected HTTP sources to be rejected, got %v", err)
+	}
+}
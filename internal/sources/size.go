@@ -0,0 +1,130 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ErrRepositoryTooLarge is wrapped by errors reporting a repository that
+// exceeds spec.source.git.maxSize.
+var ErrRepositoryTooLarge = errors.New("repository too large")
+
+// checkSize fails when size, the space a ConfigSync takes in the cached
+// repository at repoURL, exceeds limit.
+func checkSize(repoURL string, size int64, limit *resource.Quantity) error {
+	if size > limit.Value() {
+		return fmt.Errorf("%w: %s uses %s, exceeding maxSize %s", ErrRepositoryTooLarge,
+			repoURL, resource.NewQuantity(size, resource.BinarySI), limit)
+	}
+	return nil
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// historySize returns the total size of the Git objects reachable from commit
+// in repo: the commits of its history down to the shallow boundary, and the
+// trees and blobs of each of them. Every object is counted once, at its
+// uncompressed size, so objects fetched for other refs of the same repository
+// don't count.
+func historySize(repo *git.Repository, commit *object.Commit) (int64, error) {
+	sizer, _ := repo.Storer.(interface {
+		EncodedObjectSize(plumbing.Hash) (int64, error)
+	})
+	seen := map[plumbing.Hash]bool{}
+	var size int64
+
+	add := func(h plumbing.Hash) (bool, error) {
+		if seen[h] {
+			return false, nil
+		}
+		seen[h] = true
+		if sizer != nil {
+			s, err := sizer.EncodedObjectSize(h)
+			size += s
+			return true, err
+		}
+		obj, err := repo.Storer.EncodedObject(plumbing.AnyObject, h)
+		if err != nil {
+			return true, err
+		}
+		size += obj.Size()
+		return true, nil
+	}
+
+	var addTree func(h plumbing.Hash) error
+	addTree = func(h plumbing.Hash) error {
+		if added, err := add(h); err != nil || !added {
+			return err
+		}
+		tree, err := repo.TreeObject(h)
+		if err != nil {
+			return err
+		}
+		for _, entry := range tree.Entries {
+			switch entry.Mode {
+			case filemode.Dir:
+				err = addTree(entry.Hash)
+			case filemode.Submodule:
+				// Submodule commits live in another repository
+			default:
+				_, err = add(entry.Hash)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	pending := []*object.Commit{commit}
+	for len(pending) > 0 {
+		c := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if added, err := add(c.Hash); err != nil {
+			return 0, err
+		} else if !added {
+			continue
+		}
+		if err := addTree(c.TreeHash); err != nil {
+			return 0, err
+		}
+		for _, h := range c.ParentHashes {
+			parent, err := repo.CommitObject(h)
+			if errors.Is(err, plumbing.ErrObjectNotFound) {
+				// Beyond the boundary of a shallow clone
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			pending = append(pending, parent)
+		}
+	}
+	return size, nil
+}
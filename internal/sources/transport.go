@@ -0,0 +1,144 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	gittransport "github.com/go-git/go-git/v5/plumbing/transport"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// defaultCABundleKey is read from the CA bundle reference when it sets no key.
+const defaultCABundleKey = "ca.crt"
+
+// transportOptions carry the TLS and proxy settings passed to go-git for both
+// clone and fetch.
+type transportOptions struct {
+	CABundle   []byte
+	ClientCert []byte
+	ClientKey  []byte
+	Proxy      gittransport.ProxyOptions
+}
+
+// buildTransportOptions reads the CA bundle and client certificate referenced
+// by spec.TLS and resolves the proxy for spec.RepoURL. References without a
+// namespace are looked up in namespace.
+func buildTransportOptions(
+	ctx context.Context,
+	c client.Client,
+	namespace string,
+	spec *configsv1alpha1.GitSource,
+) (transportOptions, error) {
+	var opts transportOptions
+
+	if spec.TLS != nil && spec.TLS.CABundleRef != nil {
+		ca, err := readKey(ctx, c, namespace, spec.TLS.CABundleRef, defaultCABundleKey)
+		if err != nil {
+			return opts, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		opts.CABundle = ca
+	}
+
+	if spec.TLS != nil && spec.TLS.ClientCertSecretRef != nil {
+		ref := spec.TLS.ClientCertSecretRef
+		ns := ref.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		var secret corev1.Secret
+		if err := c.Get(ctx, types.NamespacedName{Namespace: ns, Name: ref.Name}, &secret); err != nil {
+			return opts, fmt.Errorf("failed to read client certificate Secret %s/%s: %w", ns, ref.Name, err)
+		}
+		opts.ClientCert = secret.Data[corev1.TLSCertKey]
+		opts.ClientKey = secret.Data[corev1.TLSPrivateKeyKey]
+		if len(opts.ClientCert) == 0 || len(opts.ClientKey) == 0 {
+			return opts, fmt.Errorf("secret %s/%s missing %s/%s for client certificate auth",
+				ns, ref.Name, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+		}
+	}
+
+	if spec.Proxy != nil && spec.Proxy.URL != "" {
+		ep, err := gittransport.NewEndpoint(spec.RepoURL)
+		if err != nil {
+			return opts, fmt.Errorf("invalid repository URL %q: %w", spec.RepoURL, err)
+		}
+		if !bypassProxy(ep.Host, spec.Proxy.NoProxy) {
+			opts.Proxy.URL = spec.Proxy.URL
+		}
+	}
+
+	return opts, nil
+}
+
+// readKey reads a data key of the ConfigMap or Secret ref, falling back to
+// defaultKey when ref sets none.
+func readKey(ctx context.Context, c client.Client, namespace string, ref *configsv1alpha1.KeyReference, defaultKey string) ([]byte, error) {
+	ns := ref.Namespace
+	if ns == "" {
+		ns = namespace
+	}
+	key := ref.Key
+	if key == "" {
+		key = defaultKey
+	}
+	name := types.NamespacedName{Namespace: ns, Name: ref.Name}
+
+	var data []byte
+	switch ref.Kind {
+	case "ConfigMap":
+		var cm corev1.ConfigMap
+		if err := c.Get(ctx, name, &cm); err != nil {
+			return nil, fmt.Errorf("failed to read ConfigMap %s: %w", name, err)
+		}
+		data = []byte(cm.Data[key])
+	case "Secret":
+		var secret corev1.Secret
+		if err := c.Get(ctx, name, &secret); err != nil {
+			return nil, fmt.Errorf("failed to read Secret %s: %w", name, err)
+		}
+		data = secret.Data[key]
+	default:
+		return nil, fmt.Errorf("unsupported reference kind %q", ref.Kind)
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s %s has no key %q", ref.Kind, name, key)
+	}
+	return data, nil
+}
+
+// bypassProxy reports whether host matches an entry of noProxy. Entries are
+// host names (a leading `.` matches subdomains only), CIDR ranges or `*`.
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		case strings.HasPrefix(entry, "."):
+			if strings.HasSuffix(host, entry) {
+				return true
+			}
+		case strings.Contains(entry, "/"):
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		default:
+			if host == entry {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package source
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// runGit runs a git command in dir.
+func runGit(t testing.TB, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(cmd.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// newBareRepo creates a bare repository named repo.git under a new directory
+// holding a single commit, and returns that directory.
+func newBareRepo(t *testing.T) string {
+	t.Helper()
+	work := t.TempDir()
+	writeTree(t, work, map[string]string{"manifests/cm.yaml": "kind: ConfigMap\n"})
+	runGit(t, work, "init", "-q", "-b", "main")
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "-q", "-m", "init")
+
+	root := t.TempDir()
+	runGit(t, root, "clone", "-q", "--bare", work, "repo.git")
+	return root
+}
+
+// gitHandler serves the bare repositories under root over smart HTTP.
+func gitHandler(t *testing.T, root string) http.Handler {
+	t.Helper()
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	return &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+}
+
+// newClientCert returns a self-signed client certificate and key in PEM.
+func newClientCert(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "config-sync"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// serverCA returns the certificate of srv in PEM.
+func serverCA(srv *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+}
+
+// newConnectProxy starts an HTTP CONNECT proxy and returns it together with
+// the number of tunnels it opened.
+func newConnectProxy(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var tunnels atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		tunnels.Add(1)
+		w.WriteHeader(http.StatusOK)
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			_ = upstream.Close()
+			return
+		}
+		go func() {
+			_, _ = io.Copy(upstream, buf)
+			_ = upstream.Close()
+		}()
+		_, _ = io.Copy(conn, upstream)
+		_ = conn.Close()
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy, &tunnels
+}
+
+func cloneSpec(srv *httptest.Server) *configsv1alpha1.GitSource {
+	return &configsv1alpha1.GitSource{RepoURL: srv.URL + "/repo.git", Path: "manifests", AuthMethod: "none"}
+}
+
+func clone(t *testing.T, c client.Client, spec *configsv1alpha1.GitSource) error {
+	t.Helper()
+	t.Cleanup(func() { _ = Options{}.RemoveCache(spec.RepoURL) })
+	result, err := cloneOrUpdate(context.Background(), c, "default", spec, Options{})
+	if err == nil {
+		// a second call exercises the fetch path
+		result, err = cloneOrUpdate(context.Background(), c, "default", spec, Options{})
+	}
+	if err == nil {
+		if _, statErr := os.Stat(filepath.Join(result.Path, "manifests", "cm.yaml")); statErr != nil {
+			t.Fatalf("expected manifests in clone at %s", result.Path)
+		}
+	}
+	return err
+}
+
+func TestCloneWithCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(gitHandler(t, newBareRepo(t)))
+	t.Cleanup(srv.Close)
+
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "git-ca"},
+		Data:       map[string]string{"ca.crt": string(serverCA(srv))},
+	}).Build()
+
+	if err := clone(t, c, cloneSpec(srv)); err == nil {
+		t.Fatal("expected clone to fail without the CA bundle")
+	}
+
+	spec := cloneSpec(srv)
+	spec.TLS = &configsv1alpha1.GitTLS{CABundleRef: &configsv1alpha1.KeyReference{Kind: "ConfigMap", Name: "git-ca"}}
+	if err := clone(t, c, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCloneWithClientCertificate(t *testing.T) {
+	srv := httptest.NewUnstartedServer(gitHandler(t, newBareRepo(t)))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	cert, key := newClientCert(t)
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "git-tls"},
+			Data:       map[string][]byte{"ca.crt": serverCA(srv), "tls.crt": cert, "tls.key": key},
+		},
+	).Build()
+
+	spec := cloneSpec(srv)
+	spec.TLS = &configsv1alpha1.GitTLS{CABundleRef: &configsv1alpha1.KeyReference{Kind: "Secret", Name: "git-tls"}}
+	if err := clone(t, c, spec); err == nil {
+		t.Fatal("expected clone to fail without a client certificate")
+	}
+
+	spec.TLS.ClientCertSecretRef = &configsv1alpha1.ObjectRef{Name: "git-tls"}
+	if err := clone(t, c, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCloneThroughProxy(t *testing.T) {
+	srv := httptest.NewTLSServer(gitHandler(t, newBareRepo(t)))
+	t.Cleanup(srv.Close)
+	proxy, tunnels := newConnectProxy(t)
+
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "git-ca"},
+		Data:       map[string]string{"ca.crt": string(serverCA(srv))},
+	}).Build()
+	spec := cloneSpec(srv)
+	spec.TLS = &configsv1alpha1.GitTLS{CABundleRef: &configsv1alpha1.KeyReference{Kind: "ConfigMap", Name: "git-ca"}}
+	spec.Proxy = &configsv1alpha1.GitProxy{URL: proxy.URL}
+
+	if err := clone(t, c, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tunnels.Load() == 0 {
+		t.Fatal("expected the clone to go through the proxy")
+	}
+
+	tunnels.Store(0)
+	_ = Options{}.RemoveCache(spec.RepoURL)
+	spec.Proxy.NoProxy = []string{"127.0.0.0/8"}
+	if err := clone(t, c, spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := tunnels.Load(); n != 0 {
+		t.Fatalf("expected noProxy to bypass the proxy, got %d tunnels", n)
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	noProxy := []string{"git.internal", ".corp.example.com", "10.0.0.0/8"}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "git.internal", want: true},
+		{host: "GIT.internal", want: true},
+		{host: "other.internal", want: false},
+		{host: "git.corp.example.com", want: true},
+		{host: "corp.example.com", want: false},
+		{host: "10.1.2.3", want: true},
+		{host: "192.168.0.1", want: false},
+	}
+	for _, tt := range tests {
+		if got := bypassProxy(tt.host, noProxy); got != tt.want {
+			t.Errorf("bypassProxy(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+	if !bypassProxy("anything", []string{"*"}) {
+		t.Error("expected * to bypass every host")
+	}
+}
@@ -0,0 +1,259 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// ErrVerificationFailed is wrapped by errors reporting a revision that is
+// unsigned or not signed by a trusted key.
+var ErrVerificationFailed = errors.New("source verification failed")
+
+const (
+	pgpSignatureHeader = "-----BEGIN PGP SIGNATURE-----"
+	sshSignatureHeader = "-----BEGIN SSH SIGNATURE-----"
+	sshSignatureFooter = "-----END SSH SIGNATURE-----"
+	// sshSignatureNamespace is the namespace git uses for SSH signatures.
+	sshSignatureNamespace = "git"
+)
+
+// trustedKeys are the keys a revision may be signed with.
+type trustedKeys struct {
+	pgp openpgp.EntityList
+	ssh []ssh.PublicKey
+}
+
+// loadTrustedKeys reads the keys from the Secret referenced by spec. Values
+// holding an armored OpenPGP key block are parsed as such; everything else is
+// parsed as SSH public keys in authorized_keys format.
+func loadTrustedKeys(ctx context.Context, c client.Client, namespace string, spec *configsv1alpha1.GitVerification) (*trustedKeys, error) {
+	var secret corev1.Secret
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: spec.SecretRef.Name}, &secret); err != nil {
+		return nil, fmt.Errorf("failed to read trusted keys Secret %s/%s: %w", namespace, spec.SecretRef.Name, err)
+	}
+
+	keys := &trustedKeys{}
+	for name, data := range secret.Data {
+		if bytes.Contains(data, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+			entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse OpenPGP keys in %s/%s[%s]: %w", namespace, spec.SecretRef.Name, name, err)
+			}
+			keys.pgp = append(keys.pgp, entities...)
+			continue
+		}
+		for rest := data; len(bytes.TrimSpace(rest)) > 0; {
+			key, _, _, next, err := ssh.ParseAuthorizedKey(rest)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse SSH keys in %s/%s[%s]: %w", namespace, spec.SecretRef.Name, name, err)
+			}
+			keys.ssh = append(keys.ssh, key)
+			rest = next
+		}
+	}
+	if len(keys.pgp) == 0 && len(keys.ssh) == 0 {
+		return nil, fmt.Errorf("secret %s/%s holds no trusted keys", namespace, spec.SecretRef.Name)
+	}
+	return keys, nil
+}
+
+// verifyRevision checks that the commit at revision in the repository at
+// repoPath, or an annotated tag pointing at it, is signed by one of the keys
+// in the Secret referenced by spec. It returns the signer's fingerprint.
+func verifyRevision(
+	ctx context.Context,
+	c client.Client,
+	namespace, repoPath, revision string,
+	spec *configsv1alpha1.GitVerification,
+) (string, error) {
+	keys, err := loadTrustedKeys(ctx, c, namespace, spec)
+	if err != nil {
+		return "", err
+	}
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open repository: %w", err)
+	}
+	hash := plumbing.NewHash(revision)
+
+	if spec.Mode == configsv1alpha1.VerificationModeTag {
+		return verifyTags(repo, hash, keys)
+	}
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read commit %s: %w", revision, err)
+	}
+	if commit.PGPSignature == "" {
+		return "", fmt.Errorf("%w: commit %s is not signed", ErrVerificationFailed, revision)
+	}
+	payload := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(payload); err != nil {
+		return "", fmt.Errorf("failed to encode commit %s: %w", revision, err)
+	}
+	signer, err := keys.verify(payload, commit.PGPSignature)
+	if err != nil {
+		return "", fmt.Errorf("%w: commit %s: %w", ErrVerificationFailed, revision, err)
+	}
+	return signer, nil
+}
+
+// verifyTags looks for an annotated tag pointing at hash that is signed by a
+// trusted key and returns its signer's fingerprint.
+func verifyTags(repo *git.Repository, hash plumbing.Hash, keys *trustedKeys) (string, error) {
+	tags, err := repo.TagObjects()
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	var lastErr error
+	var signer string
+	err = tags.ForEach(func(tag *object.Tag) error {
+		if tag.Target != hash || tag.PGPSignature == "" {
+			return nil
+		}
+		payload := &plumbing.MemoryObject{}
+		if err := tag.EncodeWithoutSignature(payload); err != nil {
+			return fmt.Errorf("failed to encode tag %s: %w", tag.Name, err)
+		}
+		fingerprint, err := keys.verify(payload, tag.PGPSignature)
+		if err != nil {
+			lastErr = fmt.Errorf("tag %s: %w", tag.Name, err)
+			return nil
+		}
+		signer = fingerprint
+		return storer.ErrStop
+	})
+	if err != nil {
+		return "", err
+	}
+	if signer != "" {
+		return signer, nil
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("%w: %w", ErrVerificationFailed, lastErr)
+	}
+	return "", fmt.Errorf("%w: no signed annotated tag points at commit %s", ErrVerificationFailed, hash)
+}
+
+// verify checks signature over the content of payload and returns the
+// fingerprint of the trusted key that made it.
+func (k *trustedKeys) verify(payload *plumbing.MemoryObject, signature string) (string, error) {
+	r, err := payload.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = r.Close() }()
+
+	switch {
+	case strings.HasPrefix(signature, pgpSignatureHeader):
+		entity, err := openpgp.CheckArmoredDetachedSignature(k.pgp, r, strings.NewReader(signature), nil)
+		if err != nil {
+			return "", fmt.Errorf("invalid or untrusted OpenPGP signature: %w", err)
+		}
+		return fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint), nil
+	case strings.HasPrefix(signature, sshSignatureHeader):
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(r); err != nil {
+			return "", err
+		}
+		return k.verifySSH(buf.Bytes(), signature)
+	default:
+		return "", errors.New("unsupported signature format")
+	}
+}
+
+// verifySSH checks an armored SSH signature (as produced by `ssh-keygen -Y
+// sign -n git`) over message and returns the SHA256 fingerprint of its key.
+func (k *trustedKeys) verifySSH(message []byte, armored string) (string, error) {
+	body := strings.TrimSpace(armored)
+	body = strings.TrimPrefix(body, sshSignatureHeader)
+	body = strings.TrimSuffix(body, sshSignatureFooter)
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return "", fmt.Errorf("malformed SSH signature: %w", err)
+	}
+	rest, ok := bytes.CutPrefix(blob, []byte("SSHSIG"))
+	if !ok {
+		return "", errors.New("malformed SSH signature: missing SSHSIG preamble")
+	}
+
+	var sig struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if err := ssh.Unmarshal(rest, &sig); err != nil {
+		return "", fmt.Errorf("malformed SSH signature: %w", err)
+	}
+	if sig.Version != 1 {
+		return "", fmt.Errorf("unsupported SSH signature version %d", sig.Version)
+	}
+	if sig.Namespace != sshSignatureNamespace {
+		return "", fmt.Errorf("SSH signature namespace is %q, expected %q", sig.Namespace, sshSignatureNamespace)
+	}
+
+	pub, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("malformed SSH signature key: %w", err)
+	}
+	trusted := false
+	for _, key := range k.ssh {
+		if bytes.Equal(key.Marshal(), pub.Marshal()) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return "", fmt.Errorf("SSH signature made by untrusted key %s", ssh.FingerprintSHA256(pub))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("unsupported SSH signature hash %q", sig.HashAlgorithm)
+	}
+	h.Write(message)
+
+	signed := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sig.Namespace, sig.Reserved, sig.HashAlgorithm, h.Sum(nil)})...)
+
+	var signature ssh.Signature
+	if err := ssh.Unmarshal(sig.Signature, &signature); err != nil {
+		return "", fmt.Errorf("malformed SSH signature: %w", err)
+	}
+	if err := pub.Verify(signed, &signature); err != nil {
+		return "", fmt.Errorf("invalid SSH signature: %w", err)
+	}
+	return ssh.FingerprintSHA256(pub), nil
+}
@@ -0,0 +1,228 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+func newPGPEntity(t *testing.T) (*openpgp.Entity, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return entity, buf.String()
+}
+
+// sshSigner signs git objects with `ssh-keygen -Y sign`, as git does.
+type sshSigner struct {
+	keyFile string
+}
+
+func (s sshSigner) Sign(message io.Reader) ([]byte, error) {
+	cmd := exec.Command("ssh-keygen", "-Y", "sign", "-n", "git", "-f", s.keyFile)
+	cmd.Stdin = message
+	return cmd.Output()
+}
+
+func newSSHSigner(t *testing.T) (sshSigner, string) {
+	t.Helper()
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not installed")
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sshSigner{keyFile: keyFile}, string(ssh.MarshalAuthorizedKey(sshPub))
+}
+
+// commitSigned creates a repository holding one commit made with opts and
+// returns its path and the commit hash.
+func commitSigned(t *testing.T, opts *git.CommitOptions) (string, plumbing.Hash) {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, dir, map[string]string{"cm.yaml": "kind: ConfigMap\n"})
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("cm.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	opts.Author = &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	hash, err := w.Commit("init", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, hash
+}
+
+func keysClient(keys map[string]string) client.Client {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "trusted-keys"}, Data: map[string][]byte{}}
+	for k, v := range keys {
+		secret.Data[k] = []byte(v)
+	}
+	return fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(secret).Build()
+}
+
+var verifyHEAD = &configsv1alpha1.GitVerification{
+	Mode:      configsv1alpha1.VerificationModeHEAD,
+	SecretRef: configsv1alpha1.LocalObjectReference{Name: "trusted-keys"},
+}
+
+func TestVerifyRevisionPGP(t *testing.T) {
+	trusted, trustedKey := newPGPEntity(t)
+	untrusted, _ := newPGPEntity(t)
+	c := keysClient(map[string]string{"release.asc": trustedKey})
+
+	dir, hash := commitSigned(t, &git.CommitOptions{SignKey: trusted})
+	signer, err := verifyRevision(context.Background(), c, "default", dir, hash.String(), verifyHEAD)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := fmt.Sprintf("%X", trusted.PrimaryKey.Fingerprint); signer != want {
+		t.Fatalf("expected signer %s, got %s", want, signer)
+	}
+
+	for name, opts := range map[string]*git.CommitOptions{
+		"untrusted": {SignKey: untrusted},
+		"unsigned":  {},
+	} {
+		dir, hash := commitSigned(t, opts)
+		_, err := verifyRevision(context.Background(), c, "default", dir, hash.String(), verifyHEAD)
+		if !errors.Is(err, ErrVerificationFailed) {
+			t.Fatalf("%s: expected ErrVerificationFailed, got %v", name, err)
+		}
+	}
+}
+
+func TestVerifyRevisionSSH(t *testing.T) {
+	trusted, trustedKey := newSSHSigner(t)
+	untrusted, _ := newSSHSigner(t)
+	c := keysClient(map[string]string{"allowed_signers": trustedKey})
+
+	dir, hash := commitSigned(t, &git.CommitOptions{Signer: trusted})
+	signer, err := verifyRevision(context.Background(), c, "default", dir, hash.String(), verifyHEAD)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(signer, "SHA256:") {
+		t.Fatalf("expected an SSH fingerprint, got %s", signer)
+	}
+
+	dir, hash = commitSigned(t, &git.CommitOptions{Signer: untrusted})
+	_, err = verifyRevision(context.Background(), c, "default", dir, hash.String(), verifyHEAD)
+	if !errors.Is(err, ErrVerificationFailed) || !strings.Contains(err.Error(), "untrusted key") {
+		t.Fatalf("expected an untrusted key error, got %v", err)
+	}
+}
+
+func TestVerifyRevisionTag(t *testing.T) {
+	trusted, trustedKey := newPGPEntity(t)
+	c := keysClient(map[string]string{"release.asc": trustedKey})
+	spec := &configsv1alpha1.GitVerification{
+		Mode:      configsv1alpha1.VerificationModeTag,
+		SecretRef: configsv1alpha1.LocalObjectReference{Name: "trusted-keys"},
+	}
+
+	// A signed commit alone doesn't satisfy Tag mode
+	dir, hash := commitSigned(t, &git.CommitOptions{SignKey: trusted})
+	if _, err := verifyRevision(context.Background(), c, "default", dir, hash.String(), spec); !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("expected ErrVerificationFailed without a tag, got %v", err)
+	}
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.CreateTag("v1.0.0", hash, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message: "release",
+		SignKey: trusted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyRevision(context.Background(), c, "default", dir, hash.String(), spec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCloneOrUpdateVerifiesBeforeCheckout(t *testing.T) {
+	trusted, trustedKey := newPGPEntity(t)
+	c := keysClient(map[string]string{"release.asc": trustedKey})
+	opts := newTestCache(t)
+
+	dir, _ := commitSigned(t, &git.CommitOptions{})
+	spec := &configsv1alpha1.GitSource{RepoURL: dir, AuthMethod: "none", Verify: verifyHEAD}
+	if _, err := cloneOrUpdate(context.Background(), c, "default", spec, opts); !errors.Is(err, ErrVerificationFailed) {
+		t.Fatalf("expected ErrVerificationFailed, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(opts.Cache.root, checkoutsDir)); len(entries) != 0 {
+		t.Fatalf("expected the unsigned revision not to be checked out, got %v", entries)
+	}
+
+	dir, hash := commitSigned(t, &git.CommitOptions{SignKey: trusted})
+	spec.RepoURL = dir
+	result, err := cloneOrUpdate(context.Background(), c, "default", spec, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Revision != hash.String() || result.Signer != fmt.Sprintf("%X", trusted.PrimaryKey.Fingerprint) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
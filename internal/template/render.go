@@ -0,0 +1,260 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+
+	"github.com/Masterminds/sprig/v3"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+// Data is the root object passed to every template.
+type Data struct {
+	// Values holds the merged inline and referenced template values.
+	Values map[string]interface{}
+	// ConfigSync identifies the ConfigSync being reconciled.
+	ConfigSync ObjectInfo
+	// Target describes the target the file is rendered for.
+	Target TargetInfo
+	// Revision is the source revision being rendered (for example a Git SHA).
+	Revision string
+}
+
+// ObjectInfo identifies a namespaced object.
+type ObjectInfo struct {
+	Name      string
+	Namespace string
+}
+
+// TargetInfo mirrors the fields of a ConfigSync target.
+type TargetInfo struct {
+	Name      string
+	Namespace string
+	Type      string
+}
+
+// emptyIfNilFunc is the name under which emptyIfNil is registered. It is
+// appended to every printing action by printEmptyForNil.
+const emptyIfNilFunc = "configsyncEmptyIfNil"
+
+// Render executes content as a Go template named name against data. Missing
+// values render as empty strings so that Sprig's `default` works as expected.
+func Render(name string, content []byte, data Data) ([]byte, error) {
+	tmpl, err := template.New(name).Funcs(funcMap()).Option("missingkey=zero").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			printEmptyForNil(t.Tree, t.Root)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// printEmptyForNil pipes the result of every action under node that prints
+// its value through emptyIfNil. text/template prints a missing map entry as
+// "<no value>"; with this rewrite it prints nothing, while literal text in the
+// template is left alone.
+func printEmptyForNil(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			printEmptyForNil(tree, child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			// Variable declarations and assignments print nothing
+			return
+		}
+		ident := parse.NewIdentifier(emptyIfNilFunc).SetTree(tree).SetPos(n.Pos)
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos, Args: []parse.Node{ident}})
+	case *parse.IfNode:
+		printEmptyForNil(tree, n.List)
+		printEmptyForNil(tree, n.ElseList)
+	case *parse.RangeNode:
+		printEmptyForNil(tree, n.List)
+		printEmptyForNil(tree, n.ElseList)
+	case *parse.WithNode:
+		printEmptyForNil(tree, n.List)
+		printEmptyForNil(tree, n.ElseList)
+	}
+}
+
+// emptyIfNil returns an empty string for nil values and v otherwise.
+func emptyIfNil(v interface{}) interface{} {
+	if v == nil {
+		return ""
+	}
+	return v
+}
+
+// RenderFile renders a source file through Render. Files that are not valid
+// UTF-8 text are returned unchanged.
+func RenderFile(path string, content []byte, data Data) ([]byte, error) {
+	if !utf8.Valid(content) {
+		return content, nil
+	}
+	return Render(path, content, data)
+}
+
+// funcMap returns the Sprig text functions plus the YAML helpers familiar from
+// Helm. Environment access is removed so templates cannot read the operator's
+// environment.
+func funcMap() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	delete(funcs, "env")
+	delete(funcs, "expandenv")
+	funcs[emptyIfNilFunc] = emptyIfNil
+
+	funcs["toYaml"] = func(v interface{}) (string, error) {
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(out), "\n"), nil
+	}
+	funcs["fromYaml"] = func(s string) (map[string]interface{}, error) {
+		out := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(s), &out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+	return funcs
+}
+
+// LoadValues merges the values referenced by spec.ValuesFrom (in order) with the
+// inline spec.Values, which take precedence. Referenced objects are read from
+// namespace.
+func LoadValues(ctx context.Context, c client.Client, namespace string, spec *configsv1alpha1.TemplateRender) (map[string]interface{}, error) {
+	if spec == nil {
+		return map[string]interface{}{}, nil
+	}
+	return LoadValuesFrom(ctx, c, namespace, spec.ValuesFrom, spec.Values)
+}
+
+// LoadValuesFrom merges the values referenced by refs (in order) with inline,
+// which takes precedence. Referenced objects are read from namespace.
+func LoadValuesFrom(
+	ctx context.Context,
+	c client.Client,
+	namespace string,
+	refs []configsv1alpha1.ValuesReference,
+	inline *apiextensionsv1.JSON,
+) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	for _, ref := range refs {
+		data, found, err := referencedData(ctx, c, namespace, ref)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			if ref.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("%s %s/%s not found", ref.Kind, namespace, ref.Name)
+		}
+
+		if ref.Key == "" {
+			for k, v := range data {
+				values[k] = string(v)
+			}
+			continue
+		}
+
+		raw, ok := data[ref.Key]
+		if !ok {
+			if ref.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("key %q not found in %s %s/%s", ref.Key, ref.Kind, namespace, ref.Name)
+		}
+		parsed := map[string]interface{}{}
+		if err := yaml.Unmarshal(raw, &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse values from key %q of %s %s/%s: %w", ref.Key, ref.Kind, namespace, ref.Name, err)
+		}
+		values = MergeValues(values, parsed)
+	}
+
+	if inline != nil && len(inline.Raw) > 0 {
+		parsed := map[string]interface{}{}
+		if err := yaml.Unmarshal(inline.Raw, &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse inline values: %w", err)
+		}
+		values = MergeValues(values, parsed)
+	}
+
+	return values, nil
+}
+
+// referencedData returns the data of the ConfigMap or Secret behind ref.
+func referencedData(ctx context.Context, c client.Client, namespace string, ref configsv1alpha1.ValuesReference) (map[string][]byte, bool, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+
+	switch ref.Kind {
+	case "ConfigMap":
+		var cm corev1.ConfigMap
+		if err := c.Get(ctx, key, &cm); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, false, nil
+			}
+			return nil, false, fmt.Errorf("failed to read ConfigMap %s: %w", key, err)
+		}
+		data := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+		for k, v := range cm.Data {
+			data[k] = []byte(v)
+		}
+		for k, v := range cm.BinaryData {
+			data[k] = v
+		}
+		return data, true, nil
+	case "Secret":
+		var secret corev1.Secret
+		if err := c.Get(ctx, key, &secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, false, nil
+			}
+			return nil, false, fmt.Errorf("failed to read Secret %s: %w", key, err)
+		}
+		return secret.Data, true, nil
+	default:
+		return nil, false, fmt.Errorf("unsupported values kind %q", ref.Kind)
+	}
+}
+
+// MergeValues deep-merges override into base and returns base. Nested maps are
+// merged key by key; any other value in override replaces the one in base.
+func MergeValues(base, override map[string]interface{}) map[string]interface{} {
+	for k, v := range override {
+		if overrideMap, ok := v.(map[string]interface{}); ok {
+			if baseMap, ok := base[k].(map[string]interface{}); ok {
+				base[k] = MergeValues(baseMap, overrideMap)
+				continue
+			}
+		}
+		base[k] = v
+	}
+	return base
+}
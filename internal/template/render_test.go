@@ -0,0 +1,146 @@
+package template
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configsv1alpha1 "github.com/joe-bresee/config-synchronizer-operator/api/v1alpha1"
+)
+
+func TestRender(t *testing.T) {
+	data := Data{
+		Values:     map[string]interface{}{"replicas": 3, "image": map[string]interface{}{"tag": "v1.2.3"}},
+		ConfigSync: ObjectInfo{Name: "app", Namespace: "team-a"},
+		Target:     TargetInfo{Name: "web", Namespace: "prod", Type: "Deployment"},
+		Revision:   "abc123",
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "values and built-ins",
+			template: "{{ .Target.Namespace }}/{{ .ConfigSync.Name }}@{{ .Revision }} x{{ .Values.replicas }} {{ .Values.image.tag }}",
+			want:     "prod/app@abc123 x3 v1.2.3",
+		},
+		{
+			name:     "sprig default for missing values",
+			template: `{{ .Values.missing | default "fallback" | upper }}`,
+			want:     "FALLBACK",
+		},
+		{
+			name:     "missing values render empty",
+			template: "[{{ .Values.missing }}]",
+			want:     "[]",
+		},
+		{
+			name:     "missing values render empty in branches",
+			template: `{{ if true }}[{{ .Values.missing }}]{{ else }}x{{ end }}{{ range $i, $v := list 1 }}[{{ $.Values.missing }}]{{ end }}`,
+			want:     "[][]",
+		},
+		{
+			name:     "literal no value text survives",
+			template: `msg: "<no value>" {{ .Values.replicas }}`,
+			want:     `msg: "<no value>" 3`,
+		},
+		{
+			name:     "variables do not print",
+			template: `{{ $tag := .Values.image.tag }}{{ $tag }}`,
+			want:     "v1.2.3",
+		},
+		{
+			name:     "toYaml helper",
+			template: "{{ .Values.image | toYaml }}",
+			want:     "tag: v1.2.3",
+		},
+		{
+			name:     "env access is disabled",
+			template: `{{ env "HOME" }}`,
+			wantErr:  true,
+		},
+		{
+			name:     "syntax error",
+			template: "{{ .Values.replicas ",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render("test.yaml", []byte(tt.template), data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderFileSkipsBinary(t *testing.T) {
+	binary := []byte{0xff, '{', '{', 0xfe}
+	got, err := RenderFile("logo.png", binary, Data{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, binary) {
+		t.Fatalf("binary content was modified: %v", got)
+	}
+}
+
+func TestLoadValues(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: "team-a"},
+			Data:       map[string]string{"values.yaml": "image:\n  repo: nginx\n  tag: stable\nreplicas: 1\n"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "team-a"},
+			Data:       map[string][]byte{"password": []byte("hunter2")},
+		},
+	).Build()
+
+	spec := &configsv1alpha1.TemplateRender{
+		Values: &apiextensionsv1.JSON{Raw: []byte(`{"image":{"tag":"1.27"}}`)},
+		ValuesFrom: []configsv1alpha1.ValuesReference{
+			{Kind: "ConfigMap", Name: "defaults", Key: "values.yaml"},
+			{Kind: "Secret", Name: "creds"},
+			{Kind: "ConfigMap", Name: "absent", Optional: true},
+		},
+	}
+
+	values, err := LoadValues(context.Background(), c, "team-a", spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{
+		"image":    map[string]interface{}{"repo": "nginx", "tag": "1.27"},
+		"replicas": float64(1),
+		"password": "hunter2",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("got %#v, want %#v", values, want)
+	}
+
+	spec.ValuesFrom = append(spec.ValuesFrom, configsv1alpha1.ValuesReference{Kind: "Secret", Name: "absent"})
+	if _, err := LoadValues(context.Background(), c, "team-a", spec); err == nil {
+		t.Fatal("expected error for missing required reference")
+	}
+}